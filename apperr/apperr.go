@@ -0,0 +1,183 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Коды ошибок API. Коды стабильны: клиенты ветвятся по ним, а не по тексту сообщений.
+const (
+	CodeBadRequest          = "bad_request"
+	CodeValidationFailed    = "validation_failed"
+	CodeInvalidID           = "invalid_id"
+	CodePasswordTooShort    = "password_too_short"
+	CodeActionTokenInvalid  = "action_token_invalid"
+	CodeSessionRequired     = "session_required"
+	CodeMFACodeInvalid      = "mfa_code_invalid"
+	CodeUnauthorized        = "unauthorized"
+	CodeTokenInvalid        = "token_invalid"
+	CodeTokenExpired        = "token_expired"
+	CodeSessionRevoked      = "session_revoked"
+	CodeAPIKeyInvalid       = "api_key_invalid"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeMFAChallengeInvalid = "mfa_challenge_invalid"
+	CodeRefreshTokenInvalid = "refresh_token_invalid"
+	CodeOIDCLoginFailed     = "oidc_login_failed"
+	CodeForbidden           = "forbidden"
+	CodeScopeMissing        = "scope_missing"
+	CodeMFARequired         = "mfa_required"
+	CodeRegisteredUserOnly  = "registered_user_required"
+	CodeNewsForbidden       = "news_forbidden"
+	CodeNoRoleAssigned      = "no_role_assigned"
+	CodeNotFound            = "not_found"
+	CodeNewsNotFound        = "news_not_found"
+	CodeUserNotFound        = "user_not_found"
+	CodeSessionNotFound     = "session_not_found"
+	CodeAPIKeyNotFound      = "api_key_not_found"
+	CodeOIDCNotConfigured   = "oidc_not_configured"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeUserExists          = "user_exists"
+	CodeMFAAlreadyEnabled   = "mfa_already_enabled"
+	CodeMFANotEnabled       = "mfa_not_enabled"
+	CodeMFANotEnrolling     = "mfa_not_enrolling"
+	CodeBatchAborted        = "batch_aborted"
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeRateLimited         = "rate_limited"
+	CodeLoginThrottled      = "login_throttled"
+	CodeInternal            = "internal_error"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeServiceUnavailable  = "service_unavailable"
+)
+
+// definition HTTP-статус ошибки по коду. Заголовки лежат в каталогах сообщений
+// (i18n) под ключами error.<код>.
+type definition struct {
+	Status int
+}
+
+var definitions = map[string]definition{
+	CodeBadRequest:          {fiber.StatusBadRequest},
+	CodeValidationFailed:    {fiber.StatusBadRequest},
+	CodeInvalidID:           {fiber.StatusBadRequest},
+	CodePasswordTooShort:    {fiber.StatusBadRequest},
+	CodeActionTokenInvalid:  {fiber.StatusBadRequest},
+	CodeSessionRequired:     {fiber.StatusBadRequest},
+	CodeMFACodeInvalid:      {fiber.StatusBadRequest},
+	CodeUnauthorized:        {fiber.StatusUnauthorized},
+	CodeTokenInvalid:        {fiber.StatusUnauthorized},
+	CodeTokenExpired:        {fiber.StatusUnauthorized},
+	CodeSessionRevoked:      {fiber.StatusUnauthorized},
+	CodeAPIKeyInvalid:       {fiber.StatusUnauthorized},
+	CodeInvalidCredentials:  {fiber.StatusUnauthorized},
+	CodeMFAChallengeInvalid: {fiber.StatusUnauthorized},
+	CodeRefreshTokenInvalid: {fiber.StatusUnauthorized},
+	CodeOIDCLoginFailed:     {fiber.StatusUnauthorized},
+	CodeForbidden:           {fiber.StatusForbidden},
+	CodeScopeMissing:        {fiber.StatusForbidden},
+	CodeMFARequired:         {fiber.StatusForbidden},
+	CodeRegisteredUserOnly:  {fiber.StatusForbidden},
+	CodeNewsForbidden:       {fiber.StatusForbidden},
+	CodeNoRoleAssigned:      {fiber.StatusForbidden},
+	CodeNotFound:            {fiber.StatusNotFound},
+	CodeNewsNotFound:        {fiber.StatusNotFound},
+	CodeUserNotFound:        {fiber.StatusNotFound},
+	CodeSessionNotFound:     {fiber.StatusNotFound},
+	CodeAPIKeyNotFound:      {fiber.StatusNotFound},
+	CodeOIDCNotConfigured:   {fiber.StatusNotFound},
+	CodeMethodNotAllowed:    {fiber.StatusMethodNotAllowed},
+	CodeUserExists:          {fiber.StatusConflict},
+	CodeMFAAlreadyEnabled:   {fiber.StatusConflict},
+	CodeMFANotEnabled:       {fiber.StatusConflict},
+	CodeMFANotEnrolling:     {fiber.StatusConflict},
+	CodeBatchAborted:        {fiber.StatusConflict},
+	CodePayloadTooLarge:     {fiber.StatusRequestEntityTooLarge},
+	CodeRateLimited:         {fiber.StatusTooManyRequests},
+	CodeLoginThrottled:      {fiber.StatusTooManyRequests},
+	CodeInternal:            {fiber.StatusInternalServerError},
+	CodeProviderUnavailable: {fiber.StatusBadGateway},
+	CodeServiceUnavailable:  {fiber.StatusServiceUnavailable},
+}
+
+// Error ошибка приложения со стабильным кодом. Обработчики возвращают ее,
+// а ErrorHandler превращает в ответ application/problem+json.
+type Error struct {
+	Code       string
+	Status     int
+	Title      string        // Заголовок без перевода; если пуст, берется из каталога по коду
+	Detail     string        // Ключ каталога сообщений с уточнением для клиента, необязательно
+	DetailArgs []interface{} // Аргументы сообщения уточнения
+	Fields     []FieldError  // Ошибки отдельных полей тела запроса
+	Err        error         // Причина для логов, клиенту не отдается
+}
+
+// FieldError ошибка проверки одного поля. Message заполняется переводом
+// сообщения validation.<code> при формировании ответа.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"-"` // Параметр правила (например, максимальная длина)
+}
+
+func (e *Error) Error() string {
+	msg := e.Code
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+		if len(e.DetailArgs) > 0 {
+			msg += fmt.Sprintf(" %v", e.DetailArgs)
+		}
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New создает ошибку с указанным кодом; статус берется из описания кода
+func New(code string) *Error {
+	def, ok := definitions[code]
+	if !ok {
+		def = definitions[CodeInternal]
+	}
+	return &Error{Code: code, Status: def.Status}
+}
+
+// Wrap создает ошибку с кодом и причиной
+func Wrap(err error, code string) *Error {
+	e := New(code)
+	e.Err = err
+	return e
+}
+
+// WithDetail добавляет уточнение для клиента: ключ каталога сообщений и его аргументы.
+// Перевод выполняется при формировании ответа на языке запроса.
+func (e *Error) WithDetail(key string, args ...interface{}) *Error {
+	e.Detail = key
+	e.DetailArgs = args
+	return e
+}
+
+// WithFields добавляет ошибки отдельных полей
+func (e *Error) WithFields(fields []FieldError) *Error {
+	e.Fields = fields
+	return e
+}
+
+// StatusOf возвращает HTTP-статус, которым будет отвечена ошибка
+func StatusOf(err error) int {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Status
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,106 @@
+package apperr
+
+import (
+	"errors"
+	"strconv"
+
+	"test/i18n"
+	"test/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProblemContentType тип содержимого ответов с ошибками (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// problemTypeBase префикс URI типа проблемы; к нему добавляется код ошибки
+const problemTypeBase = "urn:news:problem:"
+
+// Problem тело ответа с ошибкой по RFC 7807 с расширениями code и request_id
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// fiberCodes коды для ошибок самого Fiber (маршрут не найден, слишком большое тело и т.п.)
+var fiberCodes = map[int]string{
+	fiber.StatusBadRequest:            CodeBadRequest,
+	fiber.StatusNotFound:              CodeNotFound,
+	fiber.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	fiber.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	fiber.StatusTooManyRequests:       CodeRateLimited,
+	fiber.StatusServiceUnavailable:    CodeServiceUnavailable,
+}
+
+// Handler центральный обработчик ошибок Fiber: все ошибки обработчиков и middleware
+// отдаются клиенту в формате application/problem+json
+func Handler(c *fiber.Ctx, err error) error {
+	var appErr *Error
+	if !errors.As(err, &appErr) {
+		appErr = fromUnknown(c, err)
+	}
+
+	lang := i18n.Lang(c)
+	problem := appErr.Problem(lang)
+	problem.Instance = c.Path()
+	problem.RequestID, _ = c.Locals("request_id").(string)
+
+	c.Set(fiber.HeaderContentLanguage, lang)
+	return c.Status(appErr.Status).JSON(problem, ProblemContentType)
+}
+
+// Problem переводит ошибку на язык lang. Instance и RequestID заполняет вызывающий.
+func (e *Error) Problem(lang string) Problem {
+	problem := Problem{
+		Type:   problemTypeBase + e.Code,
+		Title:  e.Title,
+		Status: e.Status,
+		Code:   e.Code,
+	}
+	if problem.Title == "" || i18n.Has("error."+e.Code) {
+		problem.Title = i18n.Translate(lang, "error."+e.Code)
+	}
+	if e.Detail != "" {
+		problem.Detail = i18n.Translate(lang, e.Detail, e.DetailArgs...)
+	}
+	for _, field := range e.Fields {
+		field.Message = TranslateField(lang, field)
+		problem.Errors = append(problem.Errors, field)
+	}
+	return problem
+}
+
+// fromUnknown приводит ошибку без кода к ошибке приложения. Ошибки Fiber сохраняют
+// свой статус, остальные считаются внутренними и записываются в лог.
+func fromUnknown(c *fiber.Ctx, err error) *Error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		if code, ok := fiberCodes[fiberErr.Code]; ok {
+			return New(code)
+		}
+		// Для прочих статусов код строится из статуса, текст берется у Fiber без перевода
+		return &Error{Code: "http_" + strconv.Itoa(fiberErr.Code), Status: fiberErr.Code, Title: fiberErr.Message}
+	}
+
+	logger.Ctx(c).WithError(err).Error("Необработанная ошибка")
+	return Wrap(err, CodeInternal)
+}
+
+// TranslateField переводит сообщение об ошибке поля по ее коду; для неизвестных
+// кодов используется общее сообщение validation.invalid
+func TranslateField(lang string, field FieldError) string {
+	key := "validation." + field.Code
+	if !i18n.Has(key) {
+		return i18n.Translate(lang, "validation.invalid")
+	}
+	if field.Param != "" {
+		return i18n.Translate(lang, key, field.Param)
+	}
+	return i18n.Translate(lang, key)
+}
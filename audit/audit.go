@@ -0,0 +1,310 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"test/auth"
+	"test/database"
+	"test/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// lockKey ключ advisory-блокировки, под которой события выстраиваются в цепочку
+const lockKey = 0x61756469 // "audi"
+
+// genesisHash предыдущий хеш первой записи журнала
+var genesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// FromRequest заполняет автора, IP и идентификатор запроса события
+func FromRequest(c *fiber.Ctx) models.AuditEvent {
+	event := models.AuditEvent{IP: c.IP()}
+	event.RequestId, _ = c.Locals("request_id").(string)
+	if p := auth.PrincipalFrom(c); p != nil {
+		event.Actor = p.Username
+		if p.UserID != 0 {
+			id := p.UserID
+			event.ActorId = &id
+		}
+	}
+	return event
+}
+
+// Snapshot сериализует состояние объекта для полей Before и After
+func Snapshot(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// journalKey ключ контекста транзакции, в котором накапливаются ее события
+type journalKey struct{}
+
+// journal события транзакции, которые еще не добавлены в журнал
+type journal struct {
+	events []models.AuditEvent
+}
+
+func journalOf(tx *gorm.DB) *journal {
+	if tx.Statement.Context == nil {
+		return nil
+	}
+	j, _ := tx.Statement.Context.Value(journalKey{}).(*journal)
+	return j
+}
+
+func withJournal(db *gorm.DB) (*gorm.DB, *journal) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	j := &journal{}
+	return db.WithContext(context.WithValue(ctx, journalKey{}, j)), j
+}
+
+// flush добавляет накопленные события в журнал
+func (j *journal) flush(tx *gorm.DB) error {
+	events := j.events
+	j.events = nil
+	return appendEvents(tx, events)
+}
+
+// Transaction выполняет fn в транзакции. События, записанные Record внутри fn,
+// добавляются в журнал последним шагом перед фиксацией, так что блокировка цепочки
+// держится только до конца транзакции, а не на всем ее протяжении.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	db, j := withJournal(db)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return j.flush(tx)
+	})
+}
+
+// Begin начинает транзакцию, события которой добавляются в журнал при Commit
+func Begin(db *gorm.DB) *gorm.DB {
+	db, _ = withJournal(db)
+	return db.Begin()
+}
+
+// Commit добавляет события транзакции, начатой Begin, в журнал и фиксирует ее.
+// Если события добавить не удалось, транзакция откатывается.
+func Commit(tx *gorm.DB) error {
+	if j := journalOf(tx); j != nil {
+		if err := j.flush(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// Savepoint выполняет fn во вложенной транзакции (точке сохранения). Если fn
+// завершилась ошибкой, изменения и записанные в ней события отбрасываются.
+func Savepoint(tx *gorm.DB, fn func(sp *gorm.DB) error) error {
+	j := journalOf(tx)
+	mark := 0
+	if j != nil {
+		mark = len(j.events)
+	}
+	err := tx.Transaction(fn)
+	if err != nil && j != nil {
+		j.events = j.events[:mark]
+	}
+	return err
+}
+
+// Record записывает событие в транзакции tx: событие фиксируется вместе с изменением,
+// которое оно описывает. В транзакциях Transaction и Begin событие добавляется в журнал
+// при фиксации, в остальных — сразу, поэтому там Record вызывается последним шагом.
+func Record(tx *gorm.DB, event models.AuditEvent) error {
+	if event.Actor == "" {
+		event.Actor = "anonymous"
+	}
+	if j := journalOf(tx); j != nil {
+		j.events = append(j.events, event)
+		return nil
+	}
+	return appendEvents(tx, []models.AuditEvent{event})
+}
+
+// appendEvents добавляет события в конец цепочки
+func appendEvents(tx *gorm.DB, events []models.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	key, err := chainKey()
+	if err != nil {
+		return err
+	}
+
+	// Параллельные транзакции добавляют записи в цепочку по очереди
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+		return err
+	}
+
+	var last models.AuditEvent
+	if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	prev := last.Hash
+	if prev == "" {
+		prev = genesisHash
+	}
+
+	// PostgreSQL хранит время с точностью до микросекунд
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for i := range events {
+		event := &events[i]
+		event.PrevHash = prev
+		event.CreatedAt = now
+		event.Keyed = true
+		event.Hash = computeHash(key, event)
+		prev = event.Hash
+	}
+	return tx.Create(&events).Error
+}
+
+// Log записывает событие, не связанное с изменением данных (например, вход), в отдельной транзакции
+func Log(ctx context.Context, event models.AuditEvent) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return Record(tx, event)
+	})
+}
+
+// ErrChainBroken журнал изменен: хеш записи не совпадает с ее содержимым или предыдущей записью
+var ErrChainBroken = errors.New("audit chain is broken")
+
+// ErrKeyNotSet не задан ключ цепочки: ни AUDIT_HMAC_KEY, ни JWT_SECRET
+var ErrKeyNotSet = errors.New("AUDIT_HMAC_KEY is not set")
+
+// chainKey ключ HMAC цепочки журнала. Хранится вне БД (AUDIT_HMAC_KEY), поэтому
+// с доступом только к БД цепочку нельзя пересчитать после подмены записей.
+// Без AUDIT_HMAC_KEY ключ выводится из JWT_SECRET.
+func chainKey() ([]byte, error) {
+	if key := viper.GetString("AUDIT_HMAC_KEY"); key != "" {
+		return []byte(key), nil
+	}
+	secret := viper.GetString("JWT_SECRET")
+	if secret == "" {
+		return nil, ErrKeyNotSet
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("audit-chain"))
+	return mac.Sum(nil), nil
+}
+
+// VerifyResult результат проверки журнала
+type VerifyResult struct {
+	Checked  int  // Проверено записей
+	Legacy   int  // Записи без ключа HMAC, сделанные до его введения
+	BrokenId uint // Первая поврежденная запись, если цепочка нарушена
+}
+
+// legacyMaxID последняя запись журнала, сделанная до введения ключа (AUDIT_LEGACY_MAX_ID).
+// Граница задается вне БД: иначе переписанную целиком цепочку SHA-256 нельзя отличить
+// от настоящей. По умолчанию записи без ключа не допускаются.
+func legacyMaxID() uint {
+	return viper.GetUint("AUDIT_LEGACY_MAX_ID")
+}
+
+// verifier проверяет записи журнала по порядку
+type verifier struct {
+	key       []byte
+	legacyMax uint
+	prev      string
+	keyed     bool
+	result    VerifyResult
+}
+
+func newVerifier(key []byte, legacyMax uint) *verifier {
+	return &verifier{key: key, legacyMax: legacyMax, prev: genesisHash}
+}
+
+// check проверяет очередную запись. Запись без ключа допустима только до границы
+// AUDIT_LEGACY_MAX_ID и до первой записи с ключом.
+func (v *verifier) check(event *models.AuditEvent) error {
+	if event.Keyed {
+		v.keyed = true
+	} else {
+		v.result.Legacy++
+	}
+	legacyOK := event.Keyed || (!v.keyed && event.Id <= v.legacyMax)
+	if !legacyOK || event.PrevHash != v.prev || event.Hash != computeHash(v.key, event) {
+		v.result.BrokenId = event.Id
+		return ErrChainBroken
+	}
+	v.prev = event.Hash
+	v.result.Checked++
+	return nil
+}
+
+// Verify проверяет цепочку хешей всего журнала. При нарушении возвращает
+// ErrChainBroken и идентификатор первой поврежденной записи.
+func Verify(ctx context.Context) (VerifyResult, error) {
+	key, err := chainKey()
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	v := newVerifier(key, legacyMaxID())
+
+	var batch []models.AuditEvent
+	err = database.DB.WithContext(ctx).FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := v.check(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	return v.result, err
+}
+
+// computeHash хеширует предыдущий хеш и содержимое события: HMAC-SHA256 с ключом
+// цепочки, а для записей, сделанных до введения ключа, — SHA-256 в прежнем формате
+func computeHash(key []byte, event *models.AuditEvent) string {
+	actorID := ""
+	if event.ActorId != nil {
+		actorID = strconv.FormatUint(uint64(*event.ActorId), 10)
+	}
+
+	// Массив JSON однозначно разделяет поля
+	fields := []string{
+		event.PrevHash,
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		event.Actor,
+		actorID,
+		event.Action,
+		event.TargetType,
+		event.TargetId,
+		event.Before,
+		event.After,
+		event.IP,
+		event.RequestId,
+	}
+	if !event.Keyed {
+		data, _ := json.Marshal(fields)
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	// Признак записи с ключом входит в хеш, чтобы его нельзя было снять
+	data, _ := json.Marshal(append(fields, "keyed"))
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"test/models"
+
+	"github.com/spf13/viper"
+)
+
+func testEvent() models.AuditEvent {
+	return models.AuditEvent{
+		PrevHash:   genesisHash,
+		CreatedAt:  time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Actor:      "alice",
+		Action:     models.AuditNewsEdit,
+		TargetType: models.AuditTargetNews,
+		TargetId:   "7",
+		Before:     `{"Title":"old"}`,
+		After:      `{"Title":"new"}`,
+		IP:         "10.0.0.1",
+		RequestId:  "req-1",
+		Keyed:      true,
+	}
+}
+
+func TestChainKey(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("AUDIT_HMAC_KEY", nil)
+		viper.Set("JWT_SECRET", nil)
+	})
+
+	viper.Set("AUDIT_HMAC_KEY", "")
+	viper.Set("JWT_SECRET", "")
+	if _, err := chainKey(); err != ErrKeyNotSet {
+		t.Fatalf("без ключей: %v, ожидалась ErrKeyNotSet", err)
+	}
+
+	viper.Set("JWT_SECRET", "jwt-secret")
+	derived, err := chainKey()
+	if err != nil || string(derived) == "jwt-secret" {
+		t.Fatalf("ключ из JWT_SECRET должен выводиться, а не совпадать с ним: %q, %v", derived, err)
+	}
+
+	viper.Set("AUDIT_HMAC_KEY", "audit-key")
+	key, err := chainKey()
+	if err != nil || string(key) != "audit-key" {
+		t.Fatalf("AUDIT_HMAC_KEY: %q, %v", key, err)
+	}
+}
+
+func TestComputeHashKeyed(t *testing.T) {
+	event := testEvent()
+	hash := computeHash([]byte("key-1"), &event)
+
+	if computeHash([]byte("key-1"), &event) != hash {
+		t.Fatal("хеш не детерминирован")
+	}
+	// Без ключа хеш не пересчитать
+	if computeHash([]byte("key-2"), &event) == hash {
+		t.Fatal("хеш не зависит от ключа")
+	}
+
+	changed := event
+	changed.After = `{"Title":"forged"}`
+	if computeHash([]byte("key-1"), &changed) == hash {
+		t.Fatal("хеш не зависит от содержимого")
+	}
+}
+
+func TestComputeHashLegacy(t *testing.T) {
+	// Записи до введения ключа проверяются по SHA-256 без ключа
+	event := testEvent()
+	event.Keyed = false
+
+	data, _ := json.Marshal([]string{
+		event.PrevHash, event.CreatedAt.Format(time.RFC3339Nano), event.Actor, "",
+		event.Action, event.TargetType, event.TargetId, event.Before, event.After, event.IP, event.RequestId,
+	})
+	sum := sha256.Sum256(data)
+	if got := computeHash([]byte("any"), &event); got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("хеш записи без ключа %s, ожидался SHA-256 %s", got, hex.EncodeToString(sum[:]))
+	}
+}
+
+func TestComputeHashKeyedFlag(t *testing.T) {
+	// Снятие признака Keyed меняет формат хеша
+	event := testEvent()
+	hash := computeHash([]byte("key-1"), &event)
+	event.Keyed = false
+	if computeHash([]byte("key-1"), &event) == hash {
+		t.Fatal("признак Keyed не входит в хеш")
+	}
+}
+
+// chain строит цепочку записей; keyed[i] — признак ключа записи с идентификатором i+1
+func chain(key []byte, keyed ...bool) []models.AuditEvent {
+	events := make([]models.AuditEvent, len(keyed))
+	prev := genesisHash
+	for i := range events {
+		events[i] = testEvent()
+		events[i].Id = uint(i + 1)
+		events[i].PrevHash = prev
+		events[i].Keyed = keyed[i]
+		events[i].Hash = computeHash(key, &events[i])
+		prev = events[i].Hash
+	}
+	return events
+}
+
+func TestVerifierLegacy(t *testing.T) {
+	key := []byte("key-1")
+
+	for _, tc := range []struct {
+		name      string
+		legacyMax uint
+		keyed     []bool
+		brokenId  uint
+		legacy    int
+	}{
+		{"только записи с ключом", 0, []bool{true, true}, 0, 0},
+		{"записи без ключа до границы", 2, []bool{false, false, true}, 0, 2},
+		{"граница не задана", 0, []bool{false, true}, 1, 1},
+		{"цепочка без ключа после границы", 1, []bool{false, false, false}, 2, 2},
+		{"запись без ключа после записи с ключом", 5, []bool{false, true, false}, 3, 2},
+	} {
+		v := newVerifier(key, tc.legacyMax)
+		var err error
+		for _, event := range chain(key, tc.keyed...) {
+			if err = v.check(&event); err != nil {
+				break
+			}
+		}
+		if tc.brokenId == 0 && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if tc.brokenId != 0 && (err != ErrChainBroken || v.result.BrokenId != tc.brokenId) {
+			t.Errorf("%s: %v, BrokenId %d, ожидалась запись %d", tc.name, err, v.result.BrokenId, tc.brokenId)
+		}
+		if v.result.Legacy != tc.legacy {
+			t.Errorf("%s: Legacy %d, ожидалось %d", tc.name, v.result.Legacy, tc.legacy)
+		}
+	}
+}
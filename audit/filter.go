@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Filter условия выборки событий журнала; пустые поля не ограничивают выборку
+type Filter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetId   string
+	RequestId  string
+	From       *time.Time
+	To         *time.Time
+}
+
+// Apply добавляет условия фильтра к запросу
+func (f Filter) Apply(db *gorm.DB) *gorm.DB {
+	if f.Actor != "" {
+		db = db.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetId != "" {
+		db = db.Where("target_id = ?", f.TargetId)
+	}
+	if f.RequestId != "" {
+		db = db.Where("request_id = ?", f.RequestId)
+	}
+	if f.From != nil {
+		db = db.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("created_at < ?", *f.To)
+	}
+	return db
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"test/database"
+	"test/models"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix отличает API-ключи от других токенов
+const apiKeyPrefix = "nk_"
+
+// RoleService роль сервисных учетных записей, входящих по API-ключу
+const RoleService = "service"
+
+var (
+	ErrInvalidAPIKey    = errors.New("invalid API key")
+	ErrAPIKeyNotAllowed = errors.New("API key is not allowed from this IP")
+)
+
+// GenerateAPIKey создает новый ключ и возвращает его вместе с открытой частью и хешем
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	public := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err = rand.Read(public); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(public)
+	key = apiKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, prefix, hashAPIKey(key), nil
+}
+
+// AuthenticateAPIKey проверяет ключ, срок действия, отзыв и список разрешенных IP
+func AuthenticateAPIKey(ctx context.Context, key, ip string) (*Principal, error) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	err := database.DB.WithContext(ctx).Where("prefix = ?", prefix).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashAPIKey(key))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now)) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(apiKey.AllowedIPs, ip) {
+		return nil, ErrAPIKeyNotAllowed
+	}
+
+	// Время последнего использования обновляем не чаще раза в минуту
+	database.DB.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", apiKey.Id, now.Add(-time.Minute)).
+		Update("last_used_at", now)
+
+	return &Principal{
+		Username: "svc:" + apiKey.ServiceAccount,
+		Role:     RoleService,
+		APIKeyID: apiKey.Id,
+		Scopes:   SplitList(apiKey.Scopes),
+	}, nil
+}
+
+// SplitList разбирает список, сохраненный через запятую
+func SplitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ValidScope сообщает, известна ли область доступа
+func ValidScope(scope string) bool {
+	switch scope {
+	case models.ScopeNewsRead, models.ScopeNewsWrite:
+		return true
+	}
+	return false
+}
+
+// ValidIPRule сообщает, является ли значение IP-адресом или подсетью в нотации CIDR
+func ValidIPRule(rule string) bool {
+	if strings.Contains(rule, "/") {
+		_, _, err := net.ParseCIDR(rule)
+		return err == nil
+	}
+	return net.ParseIP(rule) != nil
+}
+
+func ipAllowed(allowed, ip string) bool {
+	rules := SplitList(allowed)
+	if len(rules) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, rule := range rules {
+		if strings.Contains(rule, "/") {
+			if _, network, err := net.ParseCIDR(rule); err == nil && network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(rule); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"test/config"
+	"test/models"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+)
+
+// ErrOIDCNotConfigured вход через OIDC не настроен (не задан OIDC_ISSUER_URL)
+var ErrOIDCNotConfigured = errors.New("OIDC is not configured")
+
+// OIDCClient клиент OpenID Connect провайдера
+type OIDCClient struct {
+	OAuth2   oauth2.Config
+	Verifier *oidc.IDTokenVerifier
+}
+
+// OIDCClaims данные пользователя из ID-токена
+type OIDCClaims struct {
+	Subject           string   `json:"sub"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	Nonce             string   `json:"nonce"`
+	AMR               []string `json:"amr"`
+	Groups            []string `json:"-"`
+}
+
+var (
+	oidcMu     sync.Mutex
+	oidcClient *OIDCClient
+)
+
+// OIDC возвращает клиента провайдера. Discovery выполняется при первом обращении,
+// а при ошибке повторяется при следующем.
+func OIDC(ctx context.Context) (*OIDCClient, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcClient != nil {
+		return oidcClient, nil
+	}
+
+	issuer := viper.GetString("OIDC_ISSUER_URL")
+	if issuer == "" {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения конфигурации OIDC-провайдера: %v", err)
+	}
+
+	clientID := viper.GetString("OIDC_CLIENT_ID")
+	oidcClient = &OIDCClient{
+		OAuth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: viper.GetString("OIDC_CLIENT_SECRET"),
+			RedirectURL:  viper.GetString("OIDC_REDIRECT_URL"),
+			Endpoint:     provider.Endpoint(),
+			Scopes:       config.GetList("OIDC_SCOPES", oidc.ScopeOpenID, "profile", "email"),
+		},
+		Verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}
+	return oidcClient, nil
+}
+
+// ParseOIDCClaims извлекает данные пользователя из проверенного ID-токена.
+// Группы читаются из claim OIDC_GROUPS_CLAIM (по умолчанию groups).
+func ParseOIDCClaims(token *oidc.IDToken) (*OIDCClaims, error) {
+	var claims OIDCClaims
+	if err := token.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := token.Claims(&raw); err != nil {
+		return nil, err
+	}
+	groupsClaim := viper.GetString("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch groups := raw[groupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				claims.Groups = append(claims.Groups, s)
+			}
+		}
+	case string:
+		claims.Groups = strings.Fields(groups)
+	}
+
+	return &claims, nil
+}
+
+// rolePriority порядок ролей от наименее к наиболее привилегированной
+var rolePriority = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleAdmin:  3,
+}
+
+// RoleForGroups сопоставляет группы провайдера ролям по OIDC_GROUP_ROLES
+// (например "news-admins:admin,news-editors:editor") и выбирает наиболее привилегированную.
+// Если ни одна группа не сопоставлена, используется OIDC_DEFAULT_ROLE (пустое значение запрещает вход).
+func RoleForGroups(groups []string) string {
+	mapping := make(map[string]string)
+	for _, pair := range config.GetList("OIDC_GROUP_ROLES") {
+		group, role, ok := strings.Cut(pair, ":")
+		if ok {
+			mapping[strings.TrimSpace(group)] = strings.TrimSpace(role)
+		}
+	}
+
+	best := ""
+	for _, group := range groups {
+		if role, ok := mapping[group]; ok && rolePriority[role] > rolePriority[best] {
+			best = role
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	if viper.IsSet("OIDC_DEFAULT_ROLE") {
+		return viper.GetString("OIDC_DEFAULT_ROLE")
+	}
+	return models.RoleViewer
+}
+
+// MFAFromAMR сообщает, подтвердил ли провайдер вход вторым фактором (claim amr, RFC 8176)
+func MFAFromAMR(amr []string) bool {
+	for _, method := range amr {
+		switch method {
+		case "mfa", "otp", "hwk", "swk", "sms":
+			return true
+		}
+	}
+	return false
+}
+
+// PurposeOIDCState назначение токена с состоянием входа через OIDC
+const PurposeOIDCState = "oidc_state"
+
+// OIDCState параметры, которые нужно сохранить между перенаправлением к провайдеру и возвратом
+type OIDCState struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code_verifier
+}
+
+// IssueOIDCState подписывает состояние входа для хранения в cookie браузера,
+// так что возврат от провайдера может обработать любой процесс prefork
+func IssueOIDCState(s OIDCState, ttl time.Duration) (string, error) {
+	secret, err := Secret()
+	if err != nil {
+		return "", err
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":  PurposeOIDCState,
+		"state":    s.State,
+		"nonce":    s.Nonce,
+		"verifier": s.Verifier,
+		"exp":      time.Now().Add(ttl).Unix(),
+	}).SignedString(secret)
+}
+
+// ParseOIDCState проверяет подпись и срок действия состояния входа
+func ParseOIDCState(tokenString string) (*OIDCState, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if p, _ := claims["purpose"].(string); p != PurposeOIDCState {
+		return nil, ErrInvalidToken
+	}
+
+	s := &OIDCState{}
+	s.State, _ = claims["state"].(string)
+	s.Nonce, _ = claims["nonce"].(string)
+	s.Verifier, _ = claims["verifier"].(string)
+	if s.State == "" || s.Nonce == "" || s.Verifier == "" {
+		return nil, ErrInvalidToken
+	}
+	return s, nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength минимальная длина пароля
+const MinPasswordLength = 8
+
+// ErrPasswordTooShort пароль короче MinPasswordLength
+var ErrPasswordTooShort = errors.New("password is too short")
+
+// dummyHash используется при проверке пароля несуществующего пользователя,
+// чтобы время ответа не раскрывало, существует ли имя
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// HashPassword вычисляет bcrypt-хеш пароля
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сравнивает пароль с хешем. Пустой хеш сравнивается с фиктивным.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"test/config"
+	"test/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Principal аутентифицированный пользователь запроса
+type Principal struct {
+	UserID    uint // 0 для тестового пользователя из переменных окружения
+	Username  string
+	Role      string
+	MFA       bool // Вход подтвержден вторым фактором
+	APIKeyID  uint // Ключ, по которому вошла сервисная учетная запись
+	SessionID uint // Сеанс, к которому относится токен доступа
+
+	// Scopes области доступа API-ключа. nil для пользователей с токеном доступа:
+	// их права определяются ролью.
+	Scopes []string
+}
+
+// HasScope сообщает, разрешена ли пользователю область доступа
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessTokenTTL срок действия токена доступа. Токен короткоживущий: смена роли и
+// отключение учетной записи вступают в силу не позже чем через этот срок, дальше
+// клиент обновляет токен по refresh-токену.
+const AccessTokenTTL = 15 * time.Minute
+
+// IssueAccessToken создает JWT-токен доступа для пользователя
+func IssueAccessToken(p Principal) (string, error) {
+	secret, err := Secret()
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"username": p.Username,
+		"role":     p.Role,
+		"mfa":      p.MFA,
+		"exp":      time.Now().Add(AccessTokenTTL).Unix(),
+	}
+	if p.UserID != 0 {
+		claims["sub"] = strconv.FormatUint(uint64(p.UserID), 10)
+	}
+	if p.SessionID != 0 {
+		claims["sid"] = strconv.FormatUint(uint64(p.SessionID), 10)
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// PrincipalFromClaims извлекает пользователя из claims токена доступа.
+// Токены одноразовых действий (с claim purpose) токенами доступа не являются.
+func PrincipalFromClaims(claims jwt.MapClaims) (*Principal, bool) {
+	if _, ok := claims["purpose"]; ok {
+		return nil, false
+	}
+
+	username, _ := claims["username"].(string)
+	if username == "" {
+		return nil, false
+	}
+
+	p := &Principal{Username: username}
+	p.Role, _ = claims["role"].(string)
+	p.MFA, _ = claims["mfa"].(bool)
+	if sub, ok := claims["sub"].(string); ok {
+		id, err := strconv.ParseUint(sub, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		p.UserID = uint(id)
+	}
+	if sid, ok := claims["sid"].(string); ok {
+		id, err := strconv.ParseUint(sid, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		p.SessionID = uint(id)
+	}
+	return p, true
+}
+
+// SetPrincipal сохраняет пользователя в контексте запроса
+func SetPrincipal(c *fiber.Ctx, p *Principal) {
+	c.Locals("principal", p)
+	c.Locals("user_id", p.Username)
+	c.Locals("role", p.Role)
+}
+
+// PrincipalFrom возвращает пользователя текущего запроса или nil
+func PrincipalFrom(c *fiber.Ctx) *Principal {
+	p, _ := c.Locals("principal").(*Principal)
+	return p
+}
+
+// MFARequired сообщает, требует ли политика (MFA_REQUIRED_ROLES, по умолчанию admin и editor)
+// второй фактор для роли. Пустое значение отключает политику.
+func MFARequired(role string) bool {
+	for _, r := range config.GetList("MFA_REQUIRED_ROLES", models.RoleAdmin, models.RoleEditor) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"test/models"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeCount количество кодов восстановления, выдаваемых при подключении TOTP
+const RecoveryCodeCount = 10
+
+// GenerateRecoveryCodes заменяет коды восстановления пользователя новыми и возвращает их.
+// В БД хранятся только хеши, сами коды показываются пользователю один раз.
+func GenerateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	rows := make([]models.RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+		codes[i] = code[:4] + "-" + code[4:]
+		rows[i] = models.RecoveryCode{UserId: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode помечает код восстановления использованным. Возвращает false, если кода нет или он уже использован.
+func UseRecoveryCode(ctx context.Context, tx *gorm.DB, userID uint, code string) (bool, error) {
+	result := tx.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/database"
+	"test/logger"
+	"test/models"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// refreshTokenPrefix отличает refresh-токены от других токенов
+const refreshTokenPrefix = "rt_"
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionRevoked      = errors.New("session is revoked or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, session revoked")
+)
+
+// RefreshTokenTTL срок действия сеанса и его refresh-токена (REFRESH_TOKEN_TTL, по умолчанию 30 дней)
+func RefreshTokenTTL() time.Duration {
+	if ttl := viper.GetDuration("REFRESH_TOKEN_TTL"); ttl > 0 {
+		return ttl
+	}
+	return 30 * 24 * time.Hour
+}
+
+// CreateSession создает сеанс для вошедшего пользователя, записывает его
+// идентификатор в p.SessionID и возвращает refresh-токен
+func CreateSession(ctx context.Context, p *Principal, ip, userAgent string) (string, error) {
+	token, hash, err := generateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserId:           p.UserID,
+		Username:         p.Username,
+		Role:             p.Role,
+		MFA:              p.MFA,
+		Device:           DeviceFromUserAgent(userAgent),
+		IP:               ip,
+		UserAgent:        truncate(userAgent, 512),
+		RefreshTokenHash: hash,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+	}
+	if err := database.DB.WithContext(ctx).Create(&session).Error; err != nil {
+		return "", err
+	}
+
+	p.SessionID = session.Id
+	return token, nil
+}
+
+// RefreshSession меняет refresh-токен на новый (старый перестает действовать) и
+// продлевает сеанс. Возвращает сеанс и новый refresh-токен. Если предъявлен уже
+// замененный токен, сеанс отзывается и возвращается ErrRefreshTokenReused.
+func RefreshSession(ctx context.Context, token, ip string) (*models.Session, string, error) {
+	if !strings.HasPrefix(token, refreshTokenPrefix) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	hash := hashRefreshToken(token)
+
+	var session models.Session
+	err := database.DB.WithContext(ctx).Where("refresh_token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", revokeOnReuse(ctx, hash, ip)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	rotated := false
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Условное обновление не дает использовать один токен в параллельных запросах
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.Id, hash).
+			Updates(map[string]interface{}{
+				"refresh_token_hash": newHash,
+				"ip":                 ip,
+				"last_seen_at":       now,
+				"expires_at":         now.Add(RefreshTokenTTL()),
+			})
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		rotated = true
+		return tx.Create(&models.RotatedRefreshToken{Hash: hash, SessionId: session.Id, RotatedAt: now}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if !rotated {
+		// Токен заменен параллельным запросом или сеанс только что отозван
+		return nil, "", revokeOnReuse(ctx, hash, ip)
+	}
+
+	session.RefreshTokenHash = newHash
+	session.IP = ip
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(RefreshTokenTTL())
+	return &session, newToken, nil
+}
+
+// revokeOnReuse отзывает сеанс, если hash — хеш уже замененного refresh-токена, и
+// записывает событие безопасности. Возвращает ErrRefreshTokenReused, если сеанс найден,
+// иначе ErrInvalidRefreshToken.
+func revokeOnReuse(ctx context.Context, hash, ip string) error {
+	var rotated models.RotatedRefreshToken
+	err := database.DB.WithContext(ctx).Where("hash = ?", hash).First(&rotated).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.First(&session, rotated.SessionId).Error; err != nil {
+			return err
+		}
+		if session.RevokedAt == nil {
+			if err := tx.Model(&session).Update("revoked_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.SecurityEvent{
+			Type:    models.SecurityEventRefreshReuse,
+			Subject: session.Username,
+			IP:      ip,
+			Details: "session=" + strconv.FormatUint(uint64(session.Id), 10),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// CheckSession проверяет, что сеанс токена доступа не отозван, и отмечает время активности
+func CheckSession(ctx context.Context, id uint) error {
+	var session models.Session
+	err := database.DB.WithContext(ctx).Select("id", "expires_at", "revoked_at").First(&session, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return ErrSessionRevoked
+	}
+
+	// Время активности обновляем не чаще раза в минуту
+	return database.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", id, now.Add(-time.Minute)).
+		Update("last_seen_at", now).Error
+}
+
+// SessionOwner владелец сеансов. Сеансы пользователей из БД определяются по UserID,
+// по имени — только сеансы тестового пользователя из переменных окружения (UserID 0),
+// так что переименование или повторное использование имени не затрагивает чужие сеансы.
+type SessionOwner struct {
+	UserID   uint
+	Username string
+}
+
+// OwnerOf возвращает владельца сеансов пользователя
+func OwnerOf(p *Principal) SessionOwner {
+	return SessionOwner{UserID: p.UserID, Username: p.Username}
+}
+
+// scope ограничивает запрос сеансами владельца
+func (o SessionOwner) scope(db *gorm.DB) *gorm.DB {
+	if o.UserID != 0 {
+		return db.Where("user_id = ?", o.UserID)
+	}
+	return db.Where("user_id = 0 AND LOWER(username) = LOWER(?)", o.Username)
+}
+
+// ActiveSessions возвращает действующие сеансы пользователя, начиная с последних активных
+func ActiveSessions(ctx context.Context, owner SessionOwner) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.WithContext(ctx).
+		Scopes(owner.scope).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession отзывает сеанс пользователя в транзакции tx. Возвращает false, если действующего сеанса нет.
+func RevokeSession(ctx context.Context, tx *gorm.DB, owner SessionOwner, id uint) (bool, error) {
+	result := tx.WithContext(ctx).Model(&models.Session{}).
+		Scopes(owner.scope).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// RevokeSessions отзывает в транзакции tx все сеансы пользователя, кроме exceptID (0 — все),
+// и возвращает их количество
+func RevokeSessions(ctx context.Context, tx *gorm.DB, owner SessionOwner, exceptID uint) (int64, error) {
+	result := tx.WithContext(ctx).Model(&models.Session{}).
+		Scopes(owner.scope).
+		Where("id <> ? AND revoked_at IS NULL", exceptID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// StartSessionCleanup периодически удаляет хеши замененных refresh-токенов старше
+// срока действия refresh-токена
+func StartSessionCleanup(interval time.Duration) {
+	go func() {
+		for range time.NewTicker(interval).C {
+			err := database.DB.Where("rotated_at < ?", time.Now().Add(-RefreshTokenTTL())).Delete(&models.RotatedRefreshToken{}).Error
+			if err != nil {
+				logger.Logger.WithError(err).Warn("Ошибка очистки замененных refresh-токенов")
+			}
+		}
+	}()
+}
+
+// DeviceFromUserAgent кратко описывает устройство по заголовку User-Agent, например "Chrome on Windows"
+func DeviceFromUserAgent(ua string) string {
+	if ua == "" {
+		return "Unknown"
+	}
+
+	platform := "Unknown OS"
+	for _, candidate := range []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	// Порядок важен: Edge и Opera содержат "Chrome", Chrome содержит "Safari"
+	client := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, candidate.token) {
+			client = candidate.name
+			break
+		}
+	}
+	if client == "" {
+		// Неизвестный клиент: название до первого "/" или пробела
+		client, _, _ = strings.Cut(ua, "/")
+		client, _, _ = strings.Cut(client, " ")
+		client = truncate(client, 64)
+	}
+
+	if platform == "Unknown OS" {
+		return client
+	}
+	return client + " on " + platform
+}
+
+func generateRefreshToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = refreshTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"test/database"
+	"test/logger"
+	"test/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm/clause"
+)
+
+// Назначения одноразовых токенов
+const (
+	PurposePasswordReset = "password_reset"
+	PurposeEmailVerify   = "email_verify"
+	PurposeMFAChallenge  = "mfa_challenge"
+)
+
+var (
+	ErrSecretNotSet = errors.New("JWT_SECRET is not set")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenUsed    = errors.New("token already used")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Secret возвращает ключ подписи токенов из JWT_SECRET
+func Secret() ([]byte, error) {
+	secret := viper.GetString("JWT_SECRET")
+	if secret == "" {
+		return nil, ErrSecretNotSet
+	}
+	return []byte(secret), nil
+}
+
+// ParseToken проверяет подпись HMAC и срок действия токена
+func ParseToken(tokenString string) (jwt.MapClaims, error) {
+	secret, err := Secret()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// Проверяем алгоритм подписи
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return secret, nil
+	})
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, ErrTokenExpired)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// IssueActionToken создает подписанный одноразовый токен для указанного действия
+func IssueActionToken(purpose string, userID uint, ttl time.Duration) (string, error) {
+	secret, err := Secret()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": purpose,
+		"sub":     strconv.FormatUint(uint64(userID), 10),
+		"jti":     uuid.NewString(),
+		"exp":     time.Now().Add(ttl).Unix(),
+	})
+	return token.SignedString(secret)
+}
+
+// ActionToken проверенный токен действия
+type ActionToken struct {
+	UserID    uint
+	Jti       string
+	ExpiresAt time.Time
+}
+
+// ParseActionToken проверяет подпись, срок действия и назначение токена, не помечая его использованным
+func ParseActionToken(tokenString, purpose string) (*ActionToken, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if p, _ := claims["purpose"].(string); p != purpose {
+		return nil, ErrInvalidToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims["sub"].(string)
+	userID, err := strconv.ParseUint(sub, 10, 64)
+	if jti == "" || err != nil {
+		return nil, ErrInvalidToken
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &ActionToken{UserID: uint(userID), Jti: jti, ExpiresAt: exp.Time}, nil
+}
+
+// MarkUsed помечает токен использованным. Повторный вызов возвращает ErrTokenUsed.
+func (t *ActionToken) MarkUsed(ctx context.Context) error {
+	result := database.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UsedToken{Jti: t.Jti, UsedAt: time.Now(), ExpiresAt: t.ExpiresAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenUsed
+	}
+	return nil
+}
+
+// Used сообщает, помечен ли токен использованным
+func (t *ActionToken) Used(ctx context.Context) (bool, error) {
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.UsedToken{}).Where("jti = ?", t.Jti).Count(&count).Error
+	return count > 0, err
+}
+
+// ConsumeActionToken проверяет токен действия и помечает его использованным.
+// Повторное использование возвращает ErrTokenUsed.
+func ConsumeActionToken(ctx context.Context, tokenString, purpose string) (uint, error) {
+	token, err := ParseActionToken(tokenString, purpose)
+	if err != nil {
+		return 0, err
+	}
+	if err := token.MarkUsed(ctx); err != nil {
+		return 0, err
+	}
+	return token.UserID, nil
+}
+
+// StartUsedTokenCleanup периодически удаляет записи об использованных токенах, срок
+// действия которых истек: такие токены и без записи не пройдут проверку срока.
+// Записи хранятся еще час после истечения на случай расхождения часов серверов.
+func StartUsedTokenCleanup(interval time.Duration) {
+	go func() {
+		for range time.NewTicker(interval).C {
+			err := database.DB.Where("expires_at < ?", time.Now().Add(-time.Hour)).Delete(&models.UsedToken{}).Error
+			if err != nil {
+				logger.Logger.WithError(err).Warn("Ошибка очистки использованных токенов")
+			}
+		}
+	}()
+}
@@ -0,0 +1,194 @@
+package categories
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"test/audit"
+	"test/database"
+	"test/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockKey ключ advisory-блокировки изменений дерева категорий
+const lockKey = 0x63617467 // "catg"
+
+var (
+	// ErrNotFound категория не найдена
+	ErrNotFound = errors.New("категория не найдена")
+	// ErrCycle категорию нельзя перенести в саму себя или в своего потомка
+	ErrCycle = errors.New("перенос создает цикл в дереве категорий")
+	// ErrHasChildren у категории есть дочерние категории
+	ErrHasChildren = errors.New("у категории есть дочерние категории")
+	// ErrInUse категория назначена новостям
+	ErrInUse = errors.New("категория назначена новостям")
+)
+
+// Node категория с дочерними категориями
+type Node struct {
+	models.Category
+	Children []*Node `json:"Children"`
+}
+
+// Tree возвращает дерево категорий: корневые категории с потомками, по имени
+func Tree(ctx context.Context) ([]*Node, error) {
+	var list []models.Category
+	if err := database.DB.WithContext(ctx).Order("name, id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*Node, len(list))
+	for _, category := range list {
+		nodes[category.Id] = &Node{Category: category, Children: []*Node{}}
+	}
+	// Список отсортирован по имени, поэтому дочерние категории тоже идут по имени
+	roots := []*Node{}
+	for _, category := range list {
+		node := nodes[category.Id]
+		if category.ParentId != nil {
+			if parent, ok := nodes[*category.ParentId]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// Add создает категорию; parentID nil — корневая категория
+func Add(ctx context.Context, name string, parentID *uint, event models.AuditEvent) (*models.Category, error) {
+	category := models.Category{Name: name, ParentId: parentID}
+	err := audit.Transaction(database.DB.WithContext(ctx), func(tx *gorm.DB) error {
+		if parentID != nil {
+			if _, err := find(tx, *parentID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+		return record(tx, event, models.AuditCategoryCreate, category.Id, nil, &category)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Rename меняет название категории
+func Rename(ctx context.Context, id uint, name string, event models.AuditEvent) (*models.Category, error) {
+	return update(ctx, id, event, func(tx *gorm.DB, category *models.Category) error {
+		category.Name = name
+		return nil
+	})
+}
+
+// Move переносит категорию к другому родителю; parentID nil — в корень дерева
+func Move(ctx context.Context, id uint, parentID *uint, event models.AuditEvent) (*models.Category, error) {
+	return update(ctx, id, event, func(tx *gorm.DB, category *models.Category) error {
+		// Поднимаемся от нового родителя к корню: категория не должна встретиться на пути
+		for next := parentID; next != nil; {
+			if *next == id {
+				return ErrCycle
+			}
+			parent, err := find(tx, *next)
+			if err != nil {
+				return err
+			}
+			next = parent.ParentId
+		}
+		category.ParentId = parentID
+		return nil
+	})
+}
+
+// Delete удаляет категорию без дочерних категорий и не назначенную новостям
+func Delete(ctx context.Context, id uint, event models.AuditEvent) error {
+	return audit.Transaction(database.DB.WithContext(ctx), func(tx *gorm.DB) error {
+		category, err := find(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+		if err != nil {
+			return err
+		}
+
+		var children, uses int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrHasChildren
+		}
+		if err := tx.Model(&models.NewsCategory{}).Where("category_id = ?", id).Count(&uses).Error; err != nil {
+			return err
+		}
+		if uses > 0 {
+			return ErrInUse
+		}
+
+		if err := tx.Delete(category).Error; err != nil {
+			return err
+		}
+		return record(tx, event, models.AuditCategoryDelete, id, category, nil)
+	})
+}
+
+// update изменяет категорию под блокировкой строки и записывает изменение в журнал аудита
+func update(ctx context.Context, id uint, event models.AuditEvent, change func(tx *gorm.DB, category *models.Category) error) (*models.Category, error) {
+	var category *models.Category
+	err := audit.Transaction(database.DB.WithContext(ctx), func(tx *gorm.DB) error {
+		// Переносы сериализуются, чтобы два встречных переноса не создали цикл
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if category, err = find(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id); err != nil {
+			return err
+		}
+		before := *category
+		if err := change(tx, category); err != nil {
+			return err
+		}
+
+		err = tx.Model(category).Updates(map[string]interface{}{
+			"name":      category.Name,
+			"parent_id": category.ParentId,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return record(tx, event, models.AuditCategoryUpdate, id, &before, category)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func find(tx *gorm.DB, id uint) (*models.Category, error) {
+	var category models.Category
+	err := tx.First(&category, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func record(tx *gorm.DB, event models.AuditEvent, action string, id uint, before, after *models.Category) error {
+	event.Action = action
+	event.TargetType = models.AuditTargetCategory
+	event.TargetId = strconv.FormatUint(uint64(id), 10)
+	if before != nil {
+		event.Before = audit.Snapshot(before)
+	}
+	if after != nil {
+		event.After = audit.Snapshot(after)
+	}
+	return audit.Record(tx, event)
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"strconv"
+	"strings"
+
+	"test/categories"
+
+	"github.com/spf13/cobra"
+)
+
+func newCategoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "category",
+		Short: "Редактирование дерева категорий",
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "tree",
+			Short: "Дерево категорий",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := connect(cmd); err != nil {
+					return err
+				}
+				tree, err := categories.Tree(cmd.Context())
+				if err != nil {
+					return err
+				}
+				return render(cmd, tree, func(t *table) {
+					t.row("ID", "NAME")
+					printTree(t, tree, 0)
+				})
+			},
+		},
+		newCategoryAddCommand(),
+		&cobra.Command{
+			Use:   "rename <id> <name>",
+			Short: "Переименование категории",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				id, err := parseID(args[0])
+				if err != nil {
+					return err
+				}
+				if err := connect(cmd); err != nil {
+					return err
+				}
+				category, err := categories.Rename(cmd.Context(), id, args[1], auditEvent())
+				if err != nil {
+					return err
+				}
+				return renderCategory(cmd, category.Id, category.Name, category.ParentId)
+			},
+		},
+		newCategoryMoveCommand(),
+		&cobra.Command{
+			Use:   "delete <id>",
+			Short: "Удаление категории без дочерних категорий и новостей",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				id, err := parseID(args[0])
+				if err != nil {
+					return err
+				}
+				if err := connect(cmd); err != nil {
+					return err
+				}
+				if err := categories.Delete(cmd.Context(), id, auditEvent()); err != nil {
+					return err
+				}
+				return render(cmd, map[string]uint{"Deleted": id}, func(t *table) {
+					t.row("Удалена категория", id)
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+func newCategoryAddCommand() *cobra.Command {
+	var parent uint
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Добавление категории",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(cmd); err != nil {
+				return err
+			}
+			category, err := categories.Add(cmd.Context(), args[0], parentID(parent), auditEvent())
+			if err != nil {
+				return err
+			}
+			return renderCategory(cmd, category.Id, category.Name, category.ParentId)
+		},
+	}
+	cmd.Flags().UintVar(&parent, "parent", 0, "родительская категория; 0 — корневая категория")
+	return cmd
+}
+
+func newCategoryMoveCommand() *cobra.Command {
+	var parent uint
+	cmd := &cobra.Command{
+		Use:   "move <id>",
+		Short: "Перенос категории к другому родителю",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			if err := connect(cmd); err != nil {
+				return err
+			}
+			category, err := categories.Move(cmd.Context(), id, parentID(parent), auditEvent())
+			if err != nil {
+				return err
+			}
+			return renderCategory(cmd, category.Id, category.Name, category.ParentId)
+		},
+	}
+	cmd.Flags().UintVar(&parent, "parent", 0, "новая родительская категория; 0 — в корень дерева")
+	return cmd
+}
+
+func printTree(t *table, nodes []*categories.Node, depth int) {
+	for _, node := range nodes {
+		t.row(node.Id, strings.Repeat("  ", depth)+node.Name)
+		printTree(t, node.Children, depth+1)
+	}
+}
+
+func renderCategory(cmd *cobra.Command, id uint, name string, parent *uint) error {
+	return render(cmd, map[string]interface{}{"Id": id, "Name": name, "ParentId": parent}, func(t *table) {
+		t.row("ID", "NAME", "PARENT")
+		t.row(id, name, optional(parent))
+	})
+}
+
+// parentID флаг --parent: 0 означает корень дерева
+func parentID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func parseID(raw string) (uint, error) {
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		return 0, &strconv.NumError{Func: "ParseUint", Num: raw, Err: strconv.ErrSyntax}
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"test/database"
+	"test/i18n"
+	"test/models"
+	"test/newsio"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func newNewsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "news",
+		Short: "Выгрузка и загрузка новостей",
+	}
+	cmd.AddCommand(newNewsExportCommand(), newNewsImportCommand())
+	return cmd
+}
+
+func newNewsExportCommand() *cobra.Command {
+	var file, format string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Выгрузка всех новостей с категориями в JSON, NDJSON или CSV",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fileFormat, err := fileFormat(format, file)
+			if err != nil {
+				return err
+			}
+			if err := connect(cmd); err != nil {
+				return err
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if file != "-" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			count, err := newsio.Export(cmd.Context(), w, fileFormat)
+			if err != nil {
+				return err
+			}
+			cmd.PrintErrf("Выгружено новостей: %d\n", count)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "файл выгрузки; - для стандартного вывода")
+	cmd.Flags().StringVar(&format, "format", "", "формат: json, ndjson или csv; по умолчанию по расширению файла")
+	return cmd
+}
+
+func newNewsImportCommand() *cobra.Command {
+	var file, format, author string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Загрузка новостей из JSON, NDJSON или CSV",
+		Long: "Загружает новости пачками, каждая пачка — в своей транзакции. Записи с ошибками\n" +
+			"пропускаются и выводятся в отчете; новости с уже известным ExternalId обновляются.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fileFormat, err := fileFormat(format, file)
+			if err != nil {
+				return err
+			}
+			if err := connect(cmd); err != nil {
+				return err
+			}
+
+			event := auditEvent()
+			opts := newsio.Options{DryRun: dryRun, Event: event, Importer: models.NewsAuthor{Username: event.Actor}}
+			if author != "" {
+				var user models.User
+				err := database.DB.WithContext(cmd.Context()).Where("LOWER(username) = LOWER(?)", author).First(&user).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("пользователь %s не найден", author)
+				}
+				if err != nil {
+					return err
+				}
+				opts.Importer = models.NewsAuthor{Id: &user.Id, Username: user.Username}
+			}
+
+			var r io.Reader = cmd.InOrStdin()
+			if file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+
+			report, err := newsio.Import(cmd.Context(), r, fileFormat, opts)
+			if report != nil {
+				report.Localize(i18n.DefaultLanguage())
+				if renderErr := renderReport(cmd, report); renderErr != nil && err == nil {
+					err = renderErr
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if report.Failed > 0 {
+				return fmt.Errorf("отклонено записей: %d", report.Failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "файл для загрузки; - для стандартного ввода")
+	cmd.Flags().StringVar(&format, "format", "", "формат: json, ndjson или csv; по умолчанию по расширению файла")
+	cmd.Flags().StringVar(&author, "author", "", "пользователь, который станет автором новостей без Author")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "проверить файл и показать отчет без сохранения")
+	return cmd
+}
+
+// fileFormat формат из флага --format или, если он не задан, по расширению файла
+func fileFormat(format, file string) (newsio.Format, error) {
+	if format == "" {
+		return newsio.FormatOf(file), nil
+	}
+	return newsio.ParseFormat(format)
+}
+
+func renderReport(cmd *cobra.Command, report *newsio.Report) error {
+	return render(cmd, report, func(t *table) {
+		t.row("Прочитано записей", report.Total)
+		t.row("Создано", report.Created)
+		t.row("Обновлено", report.Updated)
+		t.row("Отклонено", report.Failed)
+		if report.DryRun {
+			t.row("Пробная загрузка, изменения не сохранены")
+		}
+		if len(report.Errors) == 0 {
+			return
+		}
+		t.row()
+		t.row("RECORD", "EXTERNAL ID", "CODE", "MESSAGE")
+		for _, e := range report.Errors {
+			message := e.Message
+			for _, field := range e.Fields {
+				message += fmt.Sprintf("; %s: %s", field.Field, field.Message)
+			}
+			t.row(e.Record, e.ExternalId, e.Code, message)
+		}
+	})
+}
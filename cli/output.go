@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Форматы вывода
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// outputFormat формат вывода из флага --output
+var outputFormat = formatTable
+
+// table вывод с выравниванием колонок
+type table struct {
+	w *tabwriter.Writer
+}
+
+func (t *table) row(values ...interface{}) {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = fmt.Sprint(value)
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+// render печатает v в JSON или таблицей, которую заполняет fill
+func render(cmd *cobra.Command, v interface{}, fill func(t *table)) error {
+	if outputFormat == formatJSON {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	t := &table{w: tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)}
+	fill(t)
+	return t.w.Flush()
+}
+
+// optional значение указателя для таблицы; "-" для nil
+func optional[T any](v *T) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(*v)
+}
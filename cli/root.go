@@ -0,0 +1,93 @@
+// Package cli команды основного бинарного файла: запуск сервера, миграции
+// и административные операции без ручной работы в psql.
+package cli
+
+import (
+	"fmt"
+	"os/user"
+
+	"test/config"
+	"test/database"
+	"test/logger"
+	"test/models"
+
+	"github.com/spf13/cobra"
+)
+
+// NewRootCommand создает корневую команду. Без подкоманды запускается сервер,
+// как и до появления CLI.
+func NewRootCommand(serve func() error) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "news",
+		Short:         "Сервис новостей",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if outputFormat != formatTable && outputFormat != formatJSON {
+				return fmt.Errorf("неизвестный формат вывода %q", outputFormat)
+			}
+			config.Load()
+			return logger.InitLogger()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve()
+		},
+	}
+	root.PersistentFlags().StringVarP(&outputFormat, "output", "o", formatTable, "формат вывода: table или json")
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Запуск HTTP-сервера",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return serve()
+			},
+		},
+		newMigrateCommand(),
+		newUserCommand(),
+		newNewsCommand(),
+		newCategoryCommand(),
+		newTokenCommand(),
+	)
+	return root
+}
+
+func newMigrateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Выполнение миграций схемы БД",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := database.Connect(); err != nil {
+				return err
+			}
+			if err := database.Migrate(); err != nil {
+				return err
+			}
+			return render(cmd, map[string]int{"SchemaVersion": database.SchemaVersion}, func(t *table) {
+				t.row("Схема обновлена до версии", database.SchemaVersion)
+			})
+		},
+	}
+}
+
+// connect подключается к БД и проверяет, что миграции выполнены
+func connect(cmd *cobra.Command) error {
+	if err := database.Connect(); err != nil {
+		return err
+	}
+	if err := database.CheckSchemaVersion(cmd.Context()); err != nil {
+		return fmt.Errorf("%w; выполните news migrate", err)
+	}
+	return nil
+}
+
+// auditEvent шаблон события журнала аудита для действий из командной строки
+func auditEvent() models.AuditEvent {
+	actor := "cli"
+	if current, err := user.Current(); err == nil {
+		actor += ":" + current.Username
+	}
+	return models.AuditEvent{Actor: actor}
+}
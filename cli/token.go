@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/models"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func newTokenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Выдача токенов",
+	}
+	cmd.AddCommand(newTokenIssueCommand())
+	return cmd
+}
+
+func newTokenIssueCommand() *cobra.Command {
+	var mfa bool
+	cmd := &cobra.Command{
+		Use:   "issue <username>",
+		Short: "Выдача токена доступа и refresh-токена пользователю",
+		Long: "Создает сеанс пользователя и выдает токен доступа и refresh-токен, например для\n" +
+			"проверки API или восстановления доступа. Сеанс виден в списке сеансов пользователя.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+
+			var user models.User
+			err := database.DB.WithContext(ctx).Where("LOWER(username) = LOWER(?)", args[0]).First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("пользователь %s не найден", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			if user.Disabled {
+				return fmt.Errorf("пользователь %s отключен", user.Username)
+			}
+
+			principal := auth.Principal{UserID: user.Id, Username: user.Username, Role: user.Role, MFA: mfa}
+			refreshToken, err := auth.CreateSession(ctx, &principal, "", "news-cli")
+			if err != nil {
+				return err
+			}
+			token, err := auth.IssueAccessToken(principal)
+			if err != nil {
+				return err
+			}
+
+			event := auditEvent()
+			event.Action = models.AuditTokenIssue
+			event.TargetType = models.AuditTargetUser
+			event.TargetId = user.Username
+			err = audit.Transaction(database.DB.WithContext(ctx), func(tx *gorm.DB) error {
+				return audit.Record(tx, event)
+			})
+			if err != nil {
+				return err
+			}
+
+			return render(cmd, map[string]interface{}{
+				"Token":        token,
+				"RefreshToken": refreshToken,
+				"SessionId":    principal.SessionID,
+			}, func(t *table) {
+				t.row("Token", token)
+				t.row("RefreshToken", refreshToken)
+				t.row("SessionId", principal.SessionID)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&mfa, "mfa", false, "отметить вход как подтвержденный вторым фактором")
+	return cmd
+}
@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/models"
+	"test/validation"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func newUserCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Управление пользователями",
+	}
+	cmd.AddCommand(newUserListCommand(), newUserCreateCommand(), newUserDisableCommand(), newUserSetRoleCommand())
+	return cmd
+}
+
+func newUserListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Список пользователей",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(cmd); err != nil {
+				return err
+			}
+
+			var users []models.User
+			if err := database.DB.WithContext(cmd.Context()).Order("id").Find(&users).Error; err != nil {
+				return err
+			}
+			return renderUsers(cmd, users...)
+		},
+	}
+}
+
+func newUserCreateCommand() *cobra.Command {
+	var req struct {
+		Username string `validate:"required,max=64"`
+		Email    string `validate:"required,email,max=255"`
+		Password string `validate:"max=72"`
+		Role     string `validate:"required,oneof=admin editor viewer"`
+		Verified bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Создание пользователя",
+		Long:  "Создание пользователя. Пароль передается флагом --password или, если указано --password -, первой строкой стандартного ввода.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if req.Password == "-" {
+				line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("чтение пароля: %w", err)
+				}
+				req.Password = strings.TrimRight(line, "\r\n")
+			}
+			if err := checkFields(&req); err != nil {
+				return err
+			}
+			hash, err := auth.HashPassword(req.Password)
+			if err != nil {
+				return err
+			}
+			if err := connect(cmd); err != nil {
+				return err
+			}
+
+			user := models.User{
+				Username:      req.Username,
+				Email:         req.Email,
+				PasswordHash:  hash,
+				Role:          req.Role,
+				EmailVerified: req.Verified,
+			}
+			err = audit.Transaction(database.DB.WithContext(cmd.Context()), func(tx *gorm.DB) error {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+
+				event := auditEvent()
+				event.Action = models.AuditUserCreate
+				event.TargetType = models.AuditTargetUser
+				event.TargetId = user.Username
+				event.After = audit.Snapshot(map[string]interface{}{"Id": user.Id, "Email": user.Email, "Role": user.Role})
+				return audit.Record(tx, event)
+			})
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return fmt.Errorf("пользователь с таким именем или адресом уже существует")
+			}
+			if err != nil {
+				return err
+			}
+			return renderUsers(cmd, user)
+		},
+	}
+	cmd.Flags().StringVar(&req.Username, "username", "", "имя пользователя")
+	cmd.Flags().StringVar(&req.Email, "email", "", "адрес электронной почты")
+	cmd.Flags().StringVar(&req.Password, "password", "", "пароль; - чтобы прочитать из стандартного ввода")
+	cmd.Flags().StringVar(&req.Role, "role", models.RoleViewer, "роль: admin, editor или viewer")
+	cmd.Flags().BoolVar(&req.Verified, "verified", false, "считать адрес подтвержденным")
+	return cmd
+}
+
+func newUserDisableCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "disable <username>",
+		Short: "Отключение учетной записи и отзыв ее сеансов",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect(cmd); err != nil {
+				return err
+			}
+
+			user, err := changeUser(cmd, args[0], func(tx *gorm.DB, user *models.User) error {
+				if user.Disabled {
+					return nil
+				}
+				event := auditEvent()
+				event.Action = models.AuditUserDisable
+				event.TargetType = models.AuditTargetUser
+				event.TargetId = user.Username
+				if err := tx.Model(user).Update("disabled", true).Error; err != nil {
+					return err
+				}
+				user.Disabled = true
+				return audit.Record(tx, event)
+			})
+			if err != nil {
+				return err
+			}
+
+			if _, err := auth.RevokeSessions(cmd.Context(), database.DB, auth.SessionOwner{UserID: user.Id, Username: user.Username}, 0); err != nil {
+				return err
+			}
+			return renderUsers(cmd, *user)
+		},
+	}
+}
+
+func newUserSetRoleCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-role <username> <role>",
+		Short: "Смена роли пользователя",
+		Long:  "Смена роли пользователя. Новая роль попадает в токен доступа при следующем обновлении по refresh-токену.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := struct {
+				Role string `validate:"required,oneof=admin editor viewer"`
+			}{Role: args[1]}
+			if err := checkFields(&req); err != nil {
+				return err
+			}
+			if err := connect(cmd); err != nil {
+				return err
+			}
+
+			user, err := changeUser(cmd, args[0], func(tx *gorm.DB, user *models.User) error {
+				if user.Role == req.Role {
+					return nil
+				}
+				event := auditEvent()
+				event.Action = models.AuditRoleChange
+				event.TargetType = models.AuditTargetUser
+				event.TargetId = user.Username
+				event.Before = audit.Snapshot(map[string]string{"Role": user.Role})
+				event.After = audit.Snapshot(map[string]string{"Role": req.Role})
+				if err := tx.Model(user).Update("role", req.Role).Error; err != nil {
+					return err
+				}
+				user.Role = req.Role
+				return audit.Record(tx, event)
+			})
+			if err != nil {
+				return err
+			}
+			return renderUsers(cmd, *user)
+		},
+	}
+}
+
+// changeUser загружает пользователя под блокировкой строки и изменяет его в транзакции
+func changeUser(cmd *cobra.Command, username string, change func(tx *gorm.DB, user *models.User) error) (*models.User, error) {
+	var user models.User
+	err := audit.Transaction(database.DB.WithContext(cmd.Context()), func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(username) = LOWER(?)", username).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+		return change(tx, &user)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("пользователь %s не найден", username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func renderUsers(cmd *cobra.Command, users ...models.User) error {
+	return render(cmd, users, func(t *table) {
+		t.row("ID", "USERNAME", "EMAIL", "ROLE", "VERIFIED", "DISABLED", "TOTP")
+		for _, u := range users {
+			t.row(u.Id, u.Username, u.Email, u.Role, u.EmailVerified, u.Disabled, u.TOTPEnabled)
+		}
+	})
+}
+
+// checkFields проверяет параметры команды по тегам validate
+func checkFields(v interface{}) error {
+	err := validation.Struct(v)
+	if fields := validation.Fields(err); len(fields) > 0 {
+		messages := make([]string, len(fields))
+		for i, field := range fields {
+			messages[i] = field.Field + ": " + field.Code
+			if field.Param != "" {
+				messages[i] += " " + field.Param
+			}
+		}
+		return fmt.Errorf("неверные параметры: %s", strings.Join(messages, ", "))
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"test/apperr"
+	"test/models"
+)
+
+// tokenResponse ответ входа и обновления токена
+type tokenResponse struct {
+	Token          string `json:"Token"`
+	RefreshToken   string `json:"RefreshToken"`
+	MfaRequired    bool   `json:"MfaRequired"`
+	ChallengeToken string `json:"ChallengeToken"`
+}
+
+// LoginResult результат входа. Если MFARequired, токены еще не выданы:
+// вход завершается вызовом LoginMFA с ChallengeToken.
+type LoginResult struct {
+	MFARequired    bool
+	ChallengeToken string
+}
+
+// Login входит по имени и паролю и сохраняет токены в клиенте
+func (c *Client) Login(ctx context.Context, username, password string) (*LoginResult, error) {
+	var resp tokenResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/login",
+		body:   map[string]string{"username": username, "password": password},
+		noAuth: true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.MfaRequired {
+		return &LoginResult{MFARequired: true, ChallengeToken: resp.ChallengeToken}, nil
+	}
+	c.setTokens(resp.Token, resp.RefreshToken)
+	return &LoginResult{}, nil
+}
+
+// LoginMFA завершает вход кодом TOTP
+func (c *Client) LoginMFA(ctx context.Context, challengeToken, code string) error {
+	return c.loginMFA(ctx, map[string]string{"challenge_token": challengeToken, "code": code})
+}
+
+// LoginRecoveryCode завершает вход кодом восстановления
+func (c *Client) LoginRecoveryCode(ctx context.Context, challengeToken, recoveryCode string) error {
+	return c.loginMFA(ctx, map[string]string{"challenge_token": challengeToken, "recovery_code": recoveryCode})
+}
+
+func (c *Client) loginMFA(ctx context.Context, body map[string]string) error {
+	var resp tokenResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/login/mfa", body: body, noAuth: true}, &resp)
+	if err != nil {
+		return err
+	}
+	c.setTokens(resp.Token, resp.RefreshToken)
+	return nil
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов. Обычно вызывать не нужно:
+// клиент обновляет токен сам, когда сервер отвечает token_expired.
+func (c *Client) Refresh(ctx context.Context) error {
+	if err := c.lockRefresh(ctx); err != nil {
+		return err
+	}
+	defer c.unlockRefresh()
+	return c.refresh(ctx)
+}
+
+// refresh обновляет токены; вызывается под блокировкой обновления
+func (c *Client) refresh(ctx context.Context) error {
+	_, refreshToken := c.Tokens()
+	if refreshToken == "" {
+		return &Error{Status: http.StatusUnauthorized, Code: apperr.CodeRefreshTokenInvalid, Title: "refresh-токен не задан"}
+	}
+
+	var resp tokenResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/token/refresh",
+		body:   map[string]string{"refresh_token": refreshToken},
+		noAuth: true,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	c.setTokens(resp.Token, resp.RefreshToken)
+	return nil
+}
+
+// ListSessions возвращает сеансы текущего пользователя
+func (c *Client) ListSessions(ctx context.Context) ([]models.Session, error) {
+	var resp struct {
+		Sessions []models.Session `json:"Sessions"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/sessions"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+// RevokeSession отзывает сеанс текущего пользователя
+func (c *Client) RevokeSession(ctx context.Context, id uint) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/sessions/" + strconv.FormatUint(uint64(id), 10)}, nil)
+}
+
+// Logout отзывает сеанс текущего токена и забывает токены
+func (c *Client) Logout(ctx context.Context) error {
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.Current {
+			if err := c.RevokeSession(ctx, session.Id); err != nil {
+				return err
+			}
+		}
+	}
+	c.setTokens("", "")
+	return nil
+}
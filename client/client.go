@@ -0,0 +1,313 @@
+// Package client типизированный клиент API новостей для других Go-сервисов.
+// Запросы и ответы описаны теми же DTO, что и на сервере (пакет models).
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test/apperr"
+)
+
+// Client клиент API. Безопасен для одновременного использования из нескольких горутин.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	apiKey     string
+	language   string
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+	onTokens     func(token, refreshToken string)
+
+	// refreshing допускает одно обновление токена за раз: refresh-токен одноразовый,
+	// и повторное предъявление уже замененного токена отзывает сеанс
+	refreshing chan struct{}
+}
+
+// RetryPolicy повтор запросов с экспоненциальной задержкой
+type RetryPolicy struct {
+	MaxAttempts int           // Всего попыток, включая первую; 1 — без повторов
+	BaseDelay   time.Duration // Задержка перед первым повтором, дальше удваивается
+	MaxDelay    time.Duration // Верхняя граница задержки, в том числе из Retry-After
+}
+
+// DefaultRetryPolicy политика повторов по умолчанию
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// Option настройка клиента
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP-клиент (таймауты, транспорт)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetry задает политику повторов
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithAPIKey аутентифицирует запросы API-ключом сервисной учетной записи
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithTokens задает сохраненные ранее токен доступа и refresh-токен
+func WithTokens(token, refreshToken string) Option {
+	return func(c *Client) { c.token, c.refreshToken = token, refreshToken }
+}
+
+// WithTokenCallback вызывается после каждого входа и обновления токенов,
+// например чтобы сохранить новый refresh-токен
+func WithTokenCallback(fn func(token, refreshToken string)) Option {
+	return func(c *Client) { c.onTokens = fn }
+}
+
+// WithLanguage задает Accept-Language для сообщений в ошибках
+func WithLanguage(lang string) Option {
+	return func(c *Client) { c.language = lang }
+}
+
+// New создает клиент для API по адресу baseURL (например, http://localhost:9000)
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+		refreshing: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c
+}
+
+// Tokens возвращает текущие токен доступа и refresh-токен
+func (c *Client) Tokens() (token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, c.refreshToken
+}
+
+func (c *Client) setTokens(token, refreshToken string) {
+	c.mu.Lock()
+	c.token, c.refreshToken = token, refreshToken
+	onTokens := c.onTokens
+	c.mu.Unlock()
+
+	if onTokens != nil {
+		onTokens(token, refreshToken)
+	}
+}
+
+// request описание запроса к API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	noAuth bool // Запрос без токена (вход, обновление токена)
+}
+
+// do выполняет запрос с повторами и обновлением токена и разбирает ответ в out.
+// Истекший токен доступа обновляется по refresh-токену один раз, затем запрос повторяется.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("кодирование тела запроса: %w", err)
+		}
+	}
+
+	refreshed := false
+	for {
+		token, _ := c.Tokens()
+		err := c.doWithRetry(ctx, req, payload, token, out)
+
+		var apiErr *Error
+		if refreshed || req.noAuth || !errors.As(err, &apiErr) || apiErr.Code != apperr.CodeTokenExpired {
+			return err
+		}
+		if err := c.refreshAfter(ctx, token); err != nil {
+			return err
+		}
+		refreshed = true
+	}
+}
+
+// refreshAfter обновляет токен, если его еще не обновил параллельный запрос.
+// Проверка и обновление выполняются под одной блокировкой, поэтому запросы, получившие
+// token_expired одновременно, дожидаются одного обновления.
+func (c *Client) refreshAfter(ctx context.Context, expired string) error {
+	if err := c.lockRefresh(ctx); err != nil {
+		return err
+	}
+	defer c.unlockRefresh()
+
+	if token, _ := c.Tokens(); token != expired {
+		return nil
+	}
+	return c.refresh(ctx)
+}
+
+// lockRefresh захватывает блокировку обновления токена или возвращает ошибку контекста
+func (c *Client) lockRefresh(ctx context.Context) error {
+	select {
+	case c.refreshing <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) unlockRefresh() {
+	<-c.refreshing
+}
+
+func (c *Client) doWithRetry(ctx context.Context, req request, payload []byte, token string, out interface{}) error {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, req, payload, token)
+		if err == nil {
+			err = decodeResponse(resp, out)
+		}
+		if err == nil || attempt >= c.retry.MaxAttempts {
+			return err
+		}
+
+		delay, retry := c.retryDelay(req.method, attempt, resp, err)
+		if !retry {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, payload []byte, token string) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.language != "" {
+		httpReq.Header.Set("Accept-Language", c.language)
+	}
+	if !req.noAuth {
+		switch {
+		case c.apiKey != "":
+			httpReq.Header.Set("X-API-Key", c.apiKey)
+		case token != "":
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return c.httpClient.Do(httpReq)
+}
+
+// retryDelay решает, повторять ли запрос, и возвращает задержку. Отклоненные до обработки
+// запросы (429, 503) повторяются для любого метода, сетевые ошибки и 502/504 — только
+// для идемпотентных методов.
+func (c *Client) retryDelay(method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+
+	idempotent := method == http.MethodGet || method == http.MethodHead ||
+		method == http.MethodPut || method == http.MethodDelete
+
+	var apiErr *Error
+	switch {
+	case !errors.As(err, &apiErr):
+		// Сетевая ошибка или неразборчивый ответ
+		if !idempotent {
+			return 0, false
+		}
+	case apiErr.Status == http.StatusTooManyRequests || apiErr.Status == http.StatusServiceUnavailable:
+		if after, ok := retryAfter(resp); ok {
+			if after > c.retry.MaxDelay {
+				return 0, false
+			}
+			return after, true
+		}
+	case apiErr.Status == http.StatusBadGateway || apiErr.Status == http.StatusGatewayTimeout:
+		if !idempotent {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	delay := c.retry.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.retry.MaxDelay {
+		delay = c.retry.MaxDelay
+	}
+	// Случайный разброс, чтобы клиенты не повторяли запросы одновременно
+	if delay > 0 {
+		delay = delay/2 + rand.N(delay/2+1)
+	}
+	return delay, true
+}
+
+// retryAfter читает Retry-After в секундах
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// decodeResponse разбирает успешный ответ в out, ответ с ошибкой — в *Error
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return errorFromResponse(resp)
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("разбор ответа %s: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
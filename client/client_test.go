@@ -0,0 +1,315 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test/apperr"
+	"test/models"
+)
+
+// noDelay повторы без ожидания, чтобы тесты шли быстро
+var noDelay = WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, code string, status int) {
+	w.Header().Set("Content-Type", apperr.ProblemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apperr.Problem{
+		Type:      "urn:news:problem:" + code,
+		Title:     code,
+		Status:    status,
+		Code:      code,
+		RequestID: "req-1",
+	})
+}
+
+func TestLoginAndCreateNews(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["username"] != "alice" || req["password"] != "secret" {
+			writeProblem(w, apperr.CodeInvalidCredentials, http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"Success": true, "Token": "access-1", "RefreshToken": "rt_1"})
+	})
+	mux.HandleFunc("POST /api/create", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			writeProblem(w, apperr.CodeUnauthorized, http.StatusUnauthorized)
+			return
+		}
+		var req models.CreateNewsRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"Success":       true,
+			"NewsId":        7,
+			"SchemaVersion": models.NewsSchemaVersion,
+			"News":          models.NewsView{Id: 7, Title: req.Title, Content: req.Content, Categories: req.Categories},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var saved string
+	c := New(server.URL, noDelay, WithTokenCallback(func(token, refreshToken string) { saved = refreshToken }))
+
+	if _, err := c.Login(context.Background(), "alice", "wrong"); !errors.Is(err, ErrInvalidLogin) {
+		t.Fatalf("ожидалась ошибка invalid_credentials, получено %v", err)
+	}
+
+	result, err := c.Login(context.Background(), "alice", "secret")
+	if err != nil || result.MFARequired {
+		t.Fatalf("вход не выполнен: %v %+v", err, result)
+	}
+	if saved != "rt_1" {
+		t.Fatalf("обратный вызов получил refresh-токен %q", saved)
+	}
+
+	news, err := c.CreateNews(context.Background(), models.CreateNewsRequest{Title: "Заголовок", Content: "Текст", Categories: []uint{1, 2}})
+	if err != nil {
+		t.Fatalf("CreateNews: %v", err)
+	}
+	if news.Id != 7 || news.Title != "Заголовок" || len(news.Categories) != 2 {
+		t.Fatalf("неожиданная новость: %+v", news)
+	}
+}
+
+func TestRefreshOnExpiredToken(t *testing.T) {
+	var refreshes atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/token/refresh", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["refresh_token"] != "rt_old" {
+			writeProblem(w, apperr.CodeRefreshTokenInvalid, http.StatusUnauthorized)
+			return
+		}
+		refreshes.Add(1)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"Success": true, "Token": "fresh", "RefreshToken": "rt_new"})
+	})
+	mux.HandleFunc("GET /api/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			writeProblem(w, apperr.CodeTokenExpired, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page") != "2" {
+			t.Errorf("параметр page = %q", r.URL.Query().Get("page"))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"Success": true, "SchemaVersion": 1, "News": []models.NewsView{{Id: 1}, {Id: 2}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := New(server.URL, noDelay, WithTokens("stale", "rt_old"))
+	news, err := c.ListNews(context.Background(), 2, 10)
+	if err != nil {
+		t.Fatalf("ListNews: %v", err)
+	}
+	if len(news) != 2 {
+		t.Fatalf("получено %d новостей", len(news))
+	}
+	if refreshes.Load() != 1 {
+		t.Fatalf("токен обновлялся %d раз", refreshes.Load())
+	}
+	if token, refreshToken := c.Tokens(); token != "fresh" || refreshToken != "rt_new" {
+		t.Fatalf("токены не сохранены: %q %q", token, refreshToken)
+	}
+}
+
+func TestConcurrentExpiryRefreshesOnce(t *testing.T) {
+	var refreshes, reused atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/token/refresh", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		// Как и сервер, замененный refresh-токен второй раз не принимаем
+		if req["refresh_token"] != "rt_old" {
+			reused.Add(1)
+			writeProblem(w, apperr.CodeRefreshTokenInvalid, http.StatusUnauthorized)
+			return
+		}
+		refreshes.Add(1)
+		// Обновление медленное, чтобы остальные запросы успели получить token_expired
+		time.Sleep(50 * time.Millisecond)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"Success": true, "Token": "fresh", "RefreshToken": "rt_new"})
+	})
+	mux.HandleFunc("GET /api/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			writeProblem(w, apperr.CodeTokenExpired, http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"Success": true, "SchemaVersion": 1, "News": []models.NewsView{{Id: 1}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := New(server.URL, noDelay, WithTokens("stale", "rt_old"))
+
+	const workers = 20
+	errs := make(chan error, workers)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			<-start
+			_, err := c.ListNews(context.Background(), 1, 10)
+			errs <- err
+		}()
+	}
+	close(start)
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("ListNews: %v", err)
+		}
+	}
+
+	if refreshes.Load() != 1 || reused.Load() != 0 {
+		t.Fatalf("обновлений: %d, повторных предъявлений refresh-токена: %d", refreshes.Load(), reused.Load())
+	}
+}
+
+func TestRefreshHonorsContext(t *testing.T) {
+	c := New("http://127.0.0.1:0", noDelay, WithTokens("stale", "rt_old"))
+	// Блокировку обновления держит другой запрос
+	c.refreshing <- struct{}{}
+	defer c.unlockRefresh()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Refresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидался context.DeadlineExceeded, получено %v", err)
+	}
+}
+
+func TestRefreshFailureIsReturned(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/token/refresh", func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, apperr.CodeRefreshTokenInvalid, http.StatusUnauthorized)
+	})
+	mux.HandleFunc("GET /api/news/{id}", func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, apperr.CodeTokenExpired, http.StatusUnauthorized)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := New(server.URL, noDelay, WithTokens("stale", "rt_revoked"))
+	if _, err := c.GetNews(context.Background(), 1); !errors.Is(err, ErrRefreshInvalid) {
+		t.Fatalf("ожидалась ошибка refresh_token_invalid, получено %v", err)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/news/404":
+			writeProblem(w, apperr.CodeNewsNotFound, http.StatusNotFound)
+		case "/api/create":
+			w.Header().Set("Content-Type", apperr.ProblemContentType)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(apperr.Problem{
+				Title:  "Ошибка проверки данных",
+				Status: http.StatusBadRequest,
+				Code:   apperr.CodeValidationFailed,
+				Errors: []apperr.FieldError{{Field: "Title", Code: "max_length", Message: "Не длиннее 255 символов"}},
+			})
+		default:
+			http.Error(w, "upstream error", http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetry(RetryPolicy{MaxAttempts: 1}), WithAPIKey("nk_test"))
+
+	_, err := c.GetNews(context.Background(), 404)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.RequestID != "req-1" {
+		t.Fatalf("неожиданная ошибка: %#v", err)
+	}
+	if !errors.Is(err, ErrNewsNotFound) || errors.Is(err, ErrForbidden) {
+		t.Fatalf("errors.Is сравнивает коды неверно: %v", err)
+	}
+
+	_, err = c.CreateNews(context.Background(), models.CreateNewsRequest{})
+	if !IsCode(err, apperr.CodeValidationFailed) || len(err.(*Error).Fields) != 1 || err.(*Error).Fields[0].Field != "Title" {
+		t.Fatalf("ошибки полей не разобраны: %#v", err)
+	}
+
+	// Ответ не в формате problem+json: код строится из статуса
+	_, err = c.ListNews(context.Background(), 0, 0)
+	if !IsCode(err, "http_502") {
+		t.Fatalf("ожидался код http_502, получено %v", err)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			writeProblem(w, apperr.CodeServiceUnavailable, http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			writeProblem(w, apperr.CodeRateLimited, http.StatusTooManyRequests)
+		default:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"Success": true})
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, noDelay)
+	if err := c.DeleteNews(context.Background(), 1); err != nil {
+		t.Fatalf("DeleteNews: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("выполнено %d попыток, ожидалось 3", calls.Load())
+	}
+}
+
+func TestNoRetryForNonIdempotentOnBadGateway(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeProblem(w, apperr.CodeProviderUnavailable, http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, noDelay)
+	if _, err := c.CreateNews(context.Background(), models.CreateNewsRequest{}); err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("POST повторен: %d попыток", calls.Load())
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, apperr.CodeServiceUnavailable, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetry(RetryPolicy{MaxAttempts: 10, BaseDelay: time.Hour, MaxDelay: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.ListNews(ctx, 1, 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидалась отмена по контексту, получено %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("ожидание повтора не прервано отменой контекста")
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"test/apperr"
+)
+
+// Error ошибка, которую вернул API (application/problem+json). Клиенты ветвятся
+// по Code — стабильным кодам из пакета apperr, — а не по тексту Title и Detail.
+type Error struct {
+	Status    int
+	Code      string
+	Title     string
+	Detail    string
+	RequestID string
+	Fields    []apperr.FieldError // Ошибки полей при validation_failed и bad_request
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("news api: %d %s: %s", e.Status, e.Code, e.Title)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, field := range e.Fields {
+		msg += fmt.Sprintf("; %s: %s", field.Field, field.Message)
+	}
+	if e.RequestID != "" {
+		msg += " (request_id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is позволяет сравнивать ошибки с шаблонами по коду: errors.Is(err, client.ErrNewsNotFound)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Status == 0 && t.Code == e.Code
+}
+
+// Шаблоны для errors.Is по наиболее частым кодам
+var (
+	ErrValidationFailed = &Error{Code: apperr.CodeValidationFailed}
+	ErrUnauthorized     = &Error{Code: apperr.CodeUnauthorized}
+	ErrInvalidLogin     = &Error{Code: apperr.CodeInvalidCredentials}
+	ErrRefreshInvalid   = &Error{Code: apperr.CodeRefreshTokenInvalid}
+	ErrForbidden        = &Error{Code: apperr.CodeForbidden}
+	ErrNewsForbidden    = &Error{Code: apperr.CodeNewsForbidden}
+	ErrNewsNotFound     = &Error{Code: apperr.CodeNewsNotFound}
+	ErrRateLimited      = &Error{Code: apperr.CodeRateLimited}
+)
+
+// IsCode сообщает, что err — ошибка API с указанным кодом
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// errorFromResponse разбирает ответ с ошибкой. Если тело не в формате problem+json
+// (например, ошибка прокси), код строится из статуса.
+func errorFromResponse(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var problem apperr.Problem
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") && json.Unmarshal(data, &problem) == nil && problem.Code != "" {
+		return &Error{
+			Status:    resp.StatusCode,
+			Code:      problem.Code,
+			Title:     problem.Title,
+			Detail:    problem.Detail,
+			RequestID: problem.RequestID,
+			Fields:    problem.Errors,
+		}
+	}
+	return &Error{
+		Status:    resp.StatusCode,
+		Code:      fmt.Sprintf("http_%d", resp.StatusCode),
+		Title:     http.StatusText(resp.StatusCode),
+		RequestID: resp.Header.Get("X-Request-ID"),
+	}
+}
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"test/client"
+	"test/models"
+)
+
+func Example() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := client.New("http://localhost:9000", client.WithLanguage("en"))
+
+	result, err := c.Login(ctx, "editor", "password")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.MFARequired {
+		if err := c.LoginMFA(ctx, result.ChallengeToken, "123456"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	news, err := c.CreateNews(ctx, models.CreateNewsRequest{
+		Title:      "Hello",
+		Content:    "First article",
+		Categories: []uint{1},
+	})
+	var apiErr *client.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == "validation_failed":
+		for _, field := range apiErr.Fields {
+			fmt.Println(field.Field, field.Message)
+		}
+		return
+	case err != nil:
+		log.Fatal(err)
+	}
+	fmt.Println("created", news.Id)
+
+	list, err := c.ListNews(ctx, 1, 20)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, item := range list {
+		fmt.Println(item.Id, item.Title)
+	}
+}
+
+func ExampleWithAPIKey() {
+	c := client.New("http://localhost:9000", client.WithAPIKey("nk_..."))
+
+	_, err := c.GetNews(context.Background(), 42)
+	if errors.Is(err, client.ErrNewsNotFound) {
+		fmt.Println("not found")
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"test/models"
+)
+
+// newsResponse ответ с одной новостью
+type newsResponse struct {
+	SchemaVersion int             `json:"SchemaVersion"`
+	News          models.NewsView `json:"News"`
+}
+
+// CreateNews создает новость и возвращает ее представление
+func (c *Client) CreateNews(ctx context.Context, req models.CreateNewsRequest) (*models.NewsView, error) {
+	var resp newsResponse
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/create", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.News, nil
+}
+
+// UpdateNews редактирует новость и возвращает ее новое представление
+func (c *Client) UpdateNews(ctx context.Context, id uint, req models.UpdateNewsRequest) (*models.NewsView, error) {
+	var resp newsResponse
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/edit/" + newsID(id), body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.News, nil
+}
+
+// DeleteNews удаляет новость
+func (c *Client) DeleteNews(ctx context.Context, id uint) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/delete/" + newsID(id)}, nil)
+}
+
+// GetNews возвращает новость с категориями и авторами
+func (c *Client) GetNews(ctx context.Context, id uint) (*models.NewsView, error) {
+	var resp newsResponse
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/news/" + newsID(id)}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.News, nil
+}
+
+// ListNews возвращает страницу новостей; page начинается с 1
+func (c *Client) ListNews(ctx context.Context, page, limit int) ([]models.NewsView, error) {
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var resp struct {
+		SchemaVersion int               `json:"SchemaVersion"`
+		News          []models.NewsView `json:"News"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/list", query: query}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.News, nil
+}
+
+func newsID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
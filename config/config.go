@@ -0,0 +1,35 @@
+package config
+
+import (
+	"log"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Load загружает конфигурацию из файла .env и переменных окружения
+func Load() {
+	// Настройка Viper для загрузки .env файла
+	viper.SetConfigFile(".env") // Указываем файл .env
+	viper.AutomaticEnv()        // Разрешаем использование переменных окружения
+
+	if err := viper.ReadInConfig(); err != nil {
+		log.Println("Файл .env не найден, используем переменные окружения системы")
+	}
+}
+
+// GetList возвращает значение переменной в виде списка, разделенного запятыми.
+// Если переменная не задана, возвращается список по умолчанию.
+func GetList(key string, defaults ...string) []string {
+	if !viper.IsSet(key) {
+		return defaults
+	}
+
+	var result []string
+	for _, item := range strings.Split(viper.GetString(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"test/models"
@@ -10,19 +12,15 @@ import (
 	"github.com/spf13/viper"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 var DB *gorm.DB
 
-func Connect() error {
-	// Настройка Viper для загрузки .env файла
-	viper.SetConfigFile(".env") // Указываем файл .env
-	viper.AutomaticEnv()        // Разрешаем использование переменных окружения
-
-	if err := viper.ReadInConfig(); err != nil {
-		log.Println("Файл .env не найден, используем переменные окружения системы")
-	}
+// SchemaVersion ожидаемая версия схемы БД. Увеличивается при каждом изменении моделей.
+const SchemaVersion = 17
 
+func Connect() error {
 	// Получение значений переменных окружения через Viper
 	dbHost := viper.GetString("DB_HOST")
 	dbPort := viper.GetString("DB_PORT")
@@ -34,13 +32,30 @@ func Connect() error {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s",
 		dbHost, dbPort, dbUser, dbName, dbPassword)
 
+	// Логи запросов через logger.Logger
+	gormLog, err := newGormLogger()
+	if err != nil {
+		return err
+	}
+
 	// Подключение к базе данных
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:         gormLog,
+		TranslateError: true, // gorm.ErrDuplicatedKey и другие ошибки вместо ошибок драйвера
+	})
 	if err != nil {
 		return fmt.Errorf("ошибка подключения к БД: %v", err)
 	}
 
+	// Трассировка запросов GORM
+	tracingOpts := []otelgorm.Option{otelgorm.WithDBName(dbName), otelgorm.WithoutMetrics()}
+	if gormLog.redactParams {
+		tracingOpts = append(tracingOpts, otelgorm.WithoutQueryVariables())
+	}
+	if err := DB.Use(otelgorm.NewPlugin(tracingOpts...)); err != nil {
+		return fmt.Errorf("ошибка подключения трассировки GORM: %v", err)
+	}
+
 	// Получение пула соединений
 	sqlDB, err := DB.DB()
 	if err != nil {
@@ -56,11 +71,114 @@ func Connect() error {
 	return nil
 }
 
+// auditImmutableSQL запрещает изменение, удаление и очистку записей журнала аудита
+const auditImmutableSQL = `
+CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER audit_events_no_update_delete
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+
+CREATE OR REPLACE TRIGGER audit_events_no_truncate
+	BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();
+`
+
 func Migrate() error {
-	err := DB.AutoMigrate(&models.News{}, &models.NewsCategory{})
+	// Признак oidc_managed появился в версии 14, до нее учетные записи OIDC создавались без пароля
+	backfillOIDCManaged := DB.Migrator().HasTable(&models.User{}) && !DB.Migrator().HasColumn(&models.User{}, "OIDCManaged")
+	// Признак author_external появился в версии 17: до нее авторы из архива сохранялись
+	// только по имени и не отличались от пользователей не из БД
+	backfillAuthorExternal := DB.Migrator().HasTable(&models.News{}) && !DB.Migrator().HasColumn(&models.News{}, "AuthorExternal")
+
+	err := DB.AutoMigrate(
+		&models.SchemaMigration{},
+		&models.News{},
+		&models.Category{},
+		&models.NewsCategory{},
+		&models.NewsCoauthor{},
+		&models.RateLimitCounter{},
+		&models.LoginFailure{},
+		&models.LoginFailureSource{},
+		&models.SecurityEvent{},
+		&models.User{},
+		&models.UsedToken{},
+		&models.OutboxEmail{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.Session{},
+		&models.RotatedRefreshToken{},
+		&models.AuditEvent{},
+	)
 	if err != nil {
 		return fmt.Errorf("ошибка при выполнении миграций: %v", err)
 	}
+
+	if backfillOIDCManaged {
+		err := DB.Model(&models.User{}).
+			Where("oidc_subject IS NOT NULL AND password_hash = ''").
+			Update("oidc_managed", true).Error
+		if err != nil {
+			return fmt.Errorf("ошибка заполнения oidc_managed: %v", err)
+		}
+	}
+	if backfillAuthorExternal {
+		err := DB.Model(&models.News{}).
+			Where("external_id IS NOT NULL AND author_id IS NULL").
+			Update("author_external", true).Error
+		if err != nil {
+			return fmt.Errorf("ошибка заполнения author_external: %v", err)
+		}
+	}
+
+	// Журнал аудита только дополняется
+	if err := DB.Exec(auditImmutableSQL).Error; err != nil {
+		return fmt.Errorf("ошибка создания триггера журнала аудита: %v", err)
+	}
+
+	// Фиксируем версию схемы, до которой выполнены миграции
+	migration := models.SchemaMigration{Version: SchemaVersion, AppliedAt: time.Now()}
+	if err := DB.Where(models.SchemaMigration{Version: SchemaVersion}).FirstOrCreate(&migration).Error; err != nil {
+		return fmt.Errorf("ошибка сохранения версии схемы: %v", err)
+	}
 	log.Println("Миграции успешно выполнены")
 	return nil
 }
+
+// Ping проверяет доступность PostgreSQL через пул соединений
+func Ping(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("ошибка получения пула соединений: %v", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// newerSchemaWarning предупреждение о более новой схеме выводится один раз на процесс
+var newerSchemaWarning sync.Once
+
+// CheckSchemaVersion проверяет, что миграции выполнены до ожидаемой версии схемы.
+// Более новая схема допускается: при поэтапном обновлении новые экземпляры мигрируют
+// БД раньше, чем останавливаются старые.
+func CheckSchemaVersion(ctx context.Context) error {
+	var version uint
+	err := DB.WithContext(ctx).Model(&models.SchemaMigration{}).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error
+	if err != nil {
+		return fmt.Errorf("ошибка чтения версии схемы: %v", err)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("версия схемы %d, ожидается %d", version, SchemaVersion)
+	}
+	if version > SchemaVersion {
+		newerSchemaWarning.Do(func() {
+			log.Printf("Версия схемы %d новее ожидаемой %d: приложение устарело", version, SchemaVersion)
+		})
+	}
+	return nil
+}
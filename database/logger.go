@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"test/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// gormLogger направляет логи GORM в logger.Logger
+type gormLogger struct {
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+	redactParams  bool
+}
+
+// newGormLogger создает логгер GORM по конфигурации: DB_LOG_LEVEL (silent/error/warn/info),
+// DB_SLOW_QUERY_THRESHOLD и DB_LOG_REDACT_PARAMS
+func newGormLogger() (*gormLogger, error) {
+	l := &gormLogger{
+		level:         gormlogger.Warn,
+		slowThreshold: 200 * time.Millisecond,
+		redactParams:  true,
+	}
+
+	switch level := viper.GetString("DB_LOG_LEVEL"); level {
+	case "":
+	case "silent":
+		l.level = gormlogger.Silent
+	case "error":
+		l.level = gormlogger.Error
+	case "warn":
+		l.level = gormlogger.Warn
+	case "info":
+		l.level = gormlogger.Info
+	default:
+		return nil, fmt.Errorf("неизвестный уровень логов БД: %s", level)
+	}
+
+	if viper.IsSet("DB_SLOW_QUERY_THRESHOLD") {
+		l.slowThreshold = viper.GetDuration("DB_SLOW_QUERY_THRESHOLD")
+	}
+	if viper.IsSet("DB_LOG_REDACT_PARAMS") {
+		l.redactParams = viper.GetBool("DB_LOG_REDACT_PARAMS")
+	}
+
+	return l, nil
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logger.FromContext(ctx).Infof(msg, data...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logger.FromContext(ctx).Warnf(msg, data...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logger.FromContext(ctx).Errorf(msg, data...)
+	}
+}
+
+// Trace пишет SQL-запрос с длительностью и количеством затронутых строк.
+// Запросы дольше порога отмечаются как медленные.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+
+	switch {
+	case failed && l.level >= gormlogger.Error:
+	case slow && l.level >= gormlogger.Warn:
+	case l.level >= gormlogger.Info:
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	entry := logger.FromContext(ctx).WithFields(logrus.Fields{
+		"sql":           sql,
+		"duration_ms":   float64(elapsed.Microseconds()) / 1000,
+		"rows_affected": rows,
+		"source":        utils.FileWithLineNum(),
+	})
+
+	switch {
+	case failed:
+		entry.WithError(err).Error("Ошибка SQL-запроса")
+	case slow:
+		entry.WithFields(logrus.Fields{
+			"slow_query":   true,
+			"threshold_ms": l.slowThreshold.Milliseconds(),
+		}).Warn("Медленный SQL-запрос")
+	default:
+		entry.Debug("SQL-запрос выполнен")
+	}
+}
+
+// ParamsFilter скрывает значения параметров запроса, если включено DB_LOG_REDACT_PARAMS
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.redactParams {
+		return sql, nil
+	}
+	return sql, params
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/i18n"
+	"test/logger"
+	"test/mail"
+	"test/models"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// ForgotPassword отправляет письмо со ссылкой для сброса пароля.
+// Ответ не зависит от того, существует ли адрес.
+func ForgotPassword(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	var user models.User
+	err := database.DB.WithContext(c.UserContext()).Where("LOWER(email) = LOWER(?)", req.Email).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		log.Info("Запрошен сброс пароля для неизвестного адреса")
+	case err != nil:
+		log.WithError(err).Error("Ошибка поиска пользователя")
+		return apperr.New(apperr.CodeInternal)
+	case user.Disabled:
+		log.WithField("user_id", user.Id).Info("Запрошен сброс пароля для отключенной учетной записи")
+	default:
+		token, err := auth.IssueActionToken(auth.PurposePasswordReset, user.Id, tokenTTL("PASSWORD_RESET_TTL", time.Hour))
+		if err != nil {
+			log.WithError(err).Error("Ошибка создания токена сброса пароля")
+			return apperr.New(apperr.CodeInternal)
+		}
+
+		if err := mail.Enqueue(database.DB.WithContext(c.UserContext()), mail.Message{
+			To:      user.Email,
+			Subject: i18n.T(c, "mail.password_reset.subject"),
+			Body:    i18n.T(c, "mail.password_reset.body", actionLink("/reset-password", token)),
+		}); err != nil {
+			log.WithError(err).Error("Ошибка постановки письма в очередь")
+			return apperr.New(apperr.CodeInternal)
+		}
+		log.WithField("user_id", user.Id).Info("Письмо для сброса пароля поставлено в очередь")
+	}
+
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.password_reset_requested"),
+	})
+}
+
+// ResetPassword устанавливает новый пароль по одноразовому токену
+func ResetPassword(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"max=72"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if errors.Is(err, auth.ErrPasswordTooShort) {
+		return apperr.New(apperr.CodePasswordTooShort).WithDetail("detail.password_too_short", auth.MinPasswordLength)
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка хеширования пароля")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	userID, err := auth.ConsumeActionToken(c.UserContext(), req.Token, auth.PurposePasswordReset)
+	if err != nil {
+		log.WithError(err).Warn("Невалидный токен сброса пароля")
+		return apperr.New(apperr.CodeActionTokenInvalid)
+	}
+
+	// Смена пароля записывается в журнал аудита от имени владельца учетной записи
+	var user models.User
+	err = audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("password_hash", hash).Error; err != nil {
+			return err
+		}
+
+		event := audit.FromRequest(c)
+		event.Actor = user.Username
+		event.ActorId = &user.Id
+		event.Action = models.AuditPasswordReset
+		event.TargetType = models.AuditTargetUser
+		event.TargetId = user.Username
+		return audit.Record(tx, event)
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка смены пароля")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	// Сеансы, открытые до сброса (в том числе украденные), перестают действовать
+	count, err := auth.RevokeSessions(c.UserContext(), database.DB, auth.SessionOwner{UserID: user.Id, Username: user.Username}, 0)
+	if err != nil {
+		log.WithError(err).Error("Ошибка отзыва сеансов после сброса пароля")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithFields(logrus.Fields{
+		"user_id":          userID,
+		"revoked_sessions": count,
+	}).Info("Пароль сброшен")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.password_changed"),
+	})
+}
+
+// RequestEmailVerification отправляет текущему пользователю письмо для подтверждения адреса
+func RequestEmailVerification(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return c.JSON(fiber.Map{
+			"Success": true,
+			"Message": i18n.T(c, "message.email_already_verified"),
+		})
+	}
+
+	if err := enqueueVerificationEmail(database.DB.WithContext(c.UserContext()), user, i18n.Lang(c)); err != nil {
+		log.WithError(err).Error("Ошибка постановки письма в очередь")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.Info("Письмо для подтверждения адреса поставлено в очередь")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.email_verification_sent"),
+	})
+}
+
+// VerifyEmail подтверждает адрес по одноразовому токену
+func VerifyEmail(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := auth.ConsumeActionToken(c.UserContext(), req.Token, auth.PurposeEmailVerify)
+	if err != nil {
+		log.WithError(err).Warn("Невалидный токен подтверждения адреса")
+		return apperr.New(apperr.CodeActionTokenInvalid)
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("email_verified", true).Error; err != nil {
+		log.WithError(err).Error("Ошибка подтверждения адреса")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("user_id", userID).Info("Адрес электронной почты подтвержден")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.email_verified"),
+	})
+}
+
+// enqueueVerificationEmail ставит в очередь письмо со ссылкой для подтверждения адреса на языке lang
+func enqueueVerificationEmail(tx *gorm.DB, user *models.User, lang string) error {
+	token, err := auth.IssueActionToken(auth.PurposeEmailVerify, user.Id, tokenTTL("EMAIL_VERIFY_TTL", 48*time.Hour))
+	if err != nil {
+		return err
+	}
+	return mail.Enqueue(tx, mail.Message{
+		To:      user.Email,
+		Subject: i18n.Translate(lang, "mail.email_verify.subject"),
+		Body:    i18n.Translate(lang, "mail.email_verify.body", actionLink("/verify-email", token)),
+	})
+}
+
+// actionLink формирует ссылку на страницу фронтенда (APP_BASE_URL) с токеном
+func actionLink(path, token string) string {
+	base := viper.GetString("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:9000"
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func tokenTTL(key string, def time.Duration) time.Duration {
+	if ttl := viper.GetDuration(key); ttl > 0 {
+		return ttl
+	}
+	return def
+}
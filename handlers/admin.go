@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"test/apperr"
+	"test/i18n"
+	"test/lockout"
+	"test/logger"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// GetLogLevel возвращает текущий уровень логгирования
+func GetLogLevel(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Level":   logger.Logger.GetLevel().String(),
+	})
+}
+
+// SetLogLevel меняет уровень логгирования без перезапуска приложения
+func SetLogLevel(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Level string `json:"Level" validate:"required,log_level"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	level, _ := logrus.ParseLevel(req.Level)
+
+	previous := logger.Logger.GetLevel()
+	if err := logger.SetLevel(level); err != nil {
+		log.WithError(err).Error("Ошибка сохранения уровня логгирования")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithFields(logrus.Fields{
+		"previous": previous.String(),
+		"level":    level.String(),
+	}).Warn("Уровень логгирования изменен")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Level":   level.String(),
+	})
+}
+
+// UnlockAccount снимает блокировку входа для учетной записи; с ips=true — и блокировки
+// IP-адресов, с которых шли неудачные попытки входа в нее
+func UnlockAccount(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	username := c.Params("username")
+	actor, _ := c.Locals("user_id").(string)
+	clearIPs := c.QueryBool("ips")
+
+	if err := lockout.Unlock(c.UserContext(), username, clearIPs, actor, c.IP()); err != nil {
+		log.WithError(err).Error("Ошибка разблокировки учетной записи")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithFields(logrus.Fields{
+		"username":  username,
+		"clear_ips": clearIPs,
+	}).Warn("Учетная запись разблокирована администратором")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.account_unlocked"),
+	})
+}
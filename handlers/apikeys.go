@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/logger"
+	"test/models"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// apiKeyView представление ключа в ответах: списки вместо строк через запятую
+func apiKeyView(key models.APIKey) fiber.Map {
+	return fiber.Map{
+		"Id":             key.Id,
+		"Name":           key.Name,
+		"ServiceAccount": key.ServiceAccount,
+		"Prefix":         key.Prefix,
+		"Scopes":         auth.SplitList(key.Scopes),
+		"AllowedIPs":     auth.SplitList(key.AllowedIPs),
+		"ExpiresAt":      key.ExpiresAt,
+		"RevokedAt":      key.RevokedAt,
+		"LastUsedAt":     key.LastUsedAt,
+		"CreatedBy":      key.CreatedBy,
+		"CreatedAt":      key.CreatedAt,
+	}
+}
+
+// CreateAPIKey выпускает API-ключ для сервисной учетной записи. Ключ возвращается только в этом ответе.
+func CreateAPIKey(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Name           string     `json:"name" validate:"required,max=128"`
+		ServiceAccount string     `json:"service_account" validate:"required,max=64"`
+		Scopes         []string   `json:"scopes" validate:"required,min=1,unique,dive,scope"`
+		AllowedIPs     []string   `json:"allowed_ips" validate:"max=32,unique,dive,ip_rule"`
+		ExpiresAt      *time.Time `json:"expires_at" validate:"omitempty,future"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.WithError(err).Error("Ошибка генерации API-ключа")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	createdBy, _ := c.Locals("user_id").(string)
+	apiKey := models.APIKey{
+		Name:           req.Name,
+		ServiceAccount: req.ServiceAccount,
+		Prefix:         prefix,
+		KeyHash:        hash,
+		Scopes:         strings.Join(req.Scopes, ","),
+		AllowedIPs:     strings.Join(req.AllowedIPs, ","),
+		ExpiresAt:      req.ExpiresAt,
+		CreatedBy:      createdBy,
+	}
+	err = audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return recordAPIKeyAudit(c, tx, models.AuditAPIKeyCreate, nil, &apiKey)
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка сохранения API-ключа")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("api_key_id", apiKey.Id).Info("API-ключ создан")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Key":     key,
+		"APIKey":  apiKeyView(apiKey),
+	})
+}
+
+// ListAPIKeys возвращает все API-ключи без секретной части
+func ListAPIKeys(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var keys []models.APIKey
+	if err := database.DB.WithContext(c.UserContext()).Order("id").Find(&keys).Error; err != nil {
+		log.WithError(err).Error("Ошибка получения API-ключей")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	views := make([]fiber.Map, 0, len(keys))
+	for _, key := range keys {
+		views = append(views, apiKeyView(key))
+	}
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"APIKeys": views,
+	})
+}
+
+// RotateAPIKey заменяет секрет ключа, сохраняя его настройки. Старый ключ перестает действовать сразу.
+func RotateAPIKey(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	apiKey, err := findActiveAPIKey(c)
+	if err != nil {
+		return err
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.WithError(err).Error("Ошибка генерации API-ключа")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	before := *apiKey
+	apiKey.Prefix = prefix
+	apiKey.KeyHash = hash
+	err = audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		err := tx.Model(apiKey).Updates(map[string]interface{}{"prefix": prefix, "key_hash": hash}).Error
+		if err != nil {
+			return err
+		}
+		return recordAPIKeyAudit(c, tx, models.AuditAPIKeyRotate, &before, apiKey)
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка сохранения API-ключа")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("api_key_id", apiKey.Id).Info("API-ключ перевыпущен")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Key":     key,
+		"APIKey":  apiKeyView(*apiKey),
+	})
+}
+
+// RevokeAPIKey отзывает API-ключ
+func RevokeAPIKey(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	apiKey, err := findActiveAPIKey(c)
+	if err != nil {
+		return err
+	}
+
+	before := *apiKey
+	now := time.Now()
+	apiKey.RevokedAt = &now
+	err = audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		if err := tx.Model(apiKey).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return recordAPIKeyAudit(c, tx, models.AuditAPIKeyRevoke, &before, apiKey)
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка отзыва API-ключа")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("api_key_id", apiKey.Id).Info("API-ключ отозван")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"APIKey":  apiKeyView(*apiKey),
+	})
+}
+
+// recordAPIKeyAudit записывает выпуск, перевыпуск или отзыв ключа в журнал аудита.
+// В журнал попадают идентификатор, префикс и настройки ключа, но не секрет и не его хеш.
+func recordAPIKeyAudit(c *fiber.Ctx, tx *gorm.DB, action string, before, after *models.APIKey) error {
+	key := after
+	if key == nil {
+		key = before
+	}
+
+	event := audit.FromRequest(c)
+	event.Action = action
+	event.TargetType = models.AuditTargetAPIKey
+	event.TargetId = strconv.FormatUint(uint64(key.Id), 10)
+	if before != nil {
+		event.Before = audit.Snapshot(apiKeyView(*before))
+	}
+	if after != nil {
+		event.After = audit.Snapshot(apiKeyView(*after))
+	}
+	return audit.Record(tx, event)
+}
+
+// findActiveAPIKey загружает неотозванный ключ по параметру :id
+func findActiveAPIKey(c *fiber.Ctx) (*models.APIKey, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, apperr.New(apperr.CodeInvalidID)
+	}
+
+	var apiKey models.APIKey
+	err = database.DB.WithContext(c.UserContext()).First(&apiKey, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && apiKey.RevokedAt != nil) {
+		return nil, apperr.New(apperr.CodeAPIKeyNotFound)
+	}
+	if err != nil {
+		logger.Ctx(c).WithError(err).Error("Ошибка получения API-ключа")
+		return nil, apperr.New(apperr.CodeInternal)
+	}
+	return &apiKey, nil
+}
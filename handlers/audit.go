@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"test/apperr"
+	"test/audit"
+	"test/database"
+	"test/logger"
+	"test/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// ListAuditEvents возвращает события журнала аудита по фильтрам, начиная с последних
+func ListAuditEvents(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	var events []models.AuditEvent
+	err = filter.Apply(database.DB.WithContext(c.UserContext())).
+		Order("id DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&events).Error
+	if err != nil {
+		log.WithError(err).Error("Ошибка получения журнала аудита")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Events":  events,
+	})
+}
+
+// ExportAuditEvents выгружает события журнала по фильтрам в формате ndjson (по умолчанию) или csv
+func ExportAuditEvents(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	format := utils.CopyString(c.Query("format", "ndjson"))
+	switch format {
+	case "ndjson":
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	default:
+		return apperr.New(apperr.CodeValidationFailed).WithDetail("detail.unknown_export_format", format)
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit.`+format+`"`)
+
+	// Ответ пишется частями после выхода из обработчика, поэтому контекст запроса не используется
+	query := filter.Apply(database.DB.Model(&models.AuditEvent{}))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var (
+			batch []models.AuditEvent
+			write func(*models.AuditEvent) error
+		)
+		if format == "csv" {
+			cw := csv.NewWriter(w)
+			cw.Write([]string{"id", "created_at", "actor", "actor_id", "action", "target_type", "target_id", "before", "after", "ip", "request_id", "prev_hash", "hash", "keyed"})
+			write = func(e *models.AuditEvent) error {
+				actorID := ""
+				if e.ActorId != nil {
+					actorID = strconv.FormatUint(uint64(*e.ActorId), 10)
+				}
+				cw.Write([]string{
+					strconv.FormatUint(uint64(e.Id), 10), e.CreatedAt.UTC().Format(time.RFC3339Nano),
+					e.Actor, actorID, e.Action, e.TargetType, e.TargetId, e.Before, e.After,
+					e.IP, e.RequestId, e.PrevHash, e.Hash, strconv.FormatBool(e.Keyed),
+				})
+				cw.Flush()
+				return cw.Error()
+			}
+		} else {
+			enc := json.NewEncoder(w)
+			write = func(e *models.AuditEvent) error { return enc.Encode(e) }
+		}
+
+		err := query.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for i := range batch {
+				if err := write(&batch[i]); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		}).Error
+		if err != nil {
+			log.WithError(err).Error("Ошибка выгрузки журнала аудита")
+		}
+	})
+	return nil
+}
+
+// VerifyAuditLog проверяет цепочку хешей журнала аудита
+func VerifyAuditLog(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	result, err := audit.Verify(c.UserContext())
+	if errors.Is(err, audit.ErrChainBroken) {
+		log.WithField("audit_event_id", result.BrokenId).Error("Цепочка журнала аудита нарушена")
+		return c.JSON(fiber.Map{
+			"Success":  true,
+			"Valid":    false,
+			"Checked":  result.Checked,
+			"Legacy":   result.Legacy,
+			"BrokenId": result.BrokenId,
+		})
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки журнала аудита")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Valid":   true,
+		"Checked": result.Checked,
+		"Legacy":  result.Legacy,
+	})
+}
+
+// parseAuditFilter читает фильтры журнала из параметров запроса; from и to в формате RFC 3339
+func parseAuditFilter(c *fiber.Ctx) (audit.Filter, error) {
+	// Значения копируются: выгрузка использует фильтр после завершения обработчика
+	filter := audit.Filter{
+		Actor:      utils.CopyString(c.Query("actor")),
+		Action:     utils.CopyString(c.Query("action")),
+		TargetType: utils.CopyString(c.Query("target_type")),
+		TargetId:   utils.CopyString(c.Query("target_id")),
+		RequestId:  utils.CopyString(c.Query("request_id")),
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, apperr.New(apperr.CodeValidationFailed).WithDetail("detail.invalid_time_param", name)
+		}
+		*dst = &t
+	}
+	return filter, nil
+}
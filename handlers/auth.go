@@ -1,73 +1,186 @@
 package handlers
 
 import (
-	"time"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"strconv"
 
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/lockout"
 	"test/logger"
+	"test/metrics"
+	"test/models"
+	"test/validation"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 )
 
 // LoginHandler обрабатывает запрос на получение JWT-токена
 func LoginHandler(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
 	type LoginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username string `json:"username" validate:"required,max=255"`
+		Password string `json:"password" validate:"required,max=72"`
 	}
 
 	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		logger.Logger.WithError(err).Warn("Ошибка парсинга тела запроса")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Bad Request: Invalid JSON",
-		})
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	userKey := lockout.UserKey(req.Username)
+	ipKey := lockout.IPKey(c.IP())
+
+	// Проверяем блокировку и задержку после предыдущих неудачных попыток
+	wait, err := lockout.Check(c.UserContext(), userKey, ipKey)
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки блокировки входа")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if wait > 0 {
+		log.WithField("retry_after", wait.String()).Warn("Попытка входа до истечения задержки или блокировки")
+		metrics.LoginAttempts.WithLabelValues("throttled").Inc()
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return apperr.New(apperr.CodeLoginThrottled)
+	}
+
+	// Проверяем учетные данные
+	principal, mfaEnabled, err := authenticate(c.UserContext(), req.Username, req.Password)
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки учетных данных")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if principal == nil {
+		log.Warn("Неверные учетные данные")
+		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		if err := lockout.RecordFailure(c.UserContext(), userKey, c.IP(), lockout.UserPolicy()); err != nil {
+			log.WithError(err).Error("Ошибка сохранения неудачной попытки входа")
+		}
+		if err := lockout.RecordFailure(c.UserContext(), ipKey, c.IP(), lockout.IPPolicy()); err != nil {
+			log.WithError(err).Error("Ошибка сохранения неудачной попытки входа")
+		}
+		auditLoginFailure(c, req.Username, "invalid_credentials")
+		return apperr.New(apperr.CodeInvalidCredentials)
+	}
+
+	// С включенным TOTP вход завершается вторым шагом (LoginMFA), там же сбрасывается счетчик ошибок
+	if mfaEnabled {
+		return respondWithMFAChallenge(c, principal)
+	}
+
+	if err := lockout.RecordSuccess(c.UserContext(), userKey); err != nil {
+		log.WithError(err).Error("Ошибка сброса неудачных попыток входа")
+	}
+
+	return respondWithToken(c, principal)
+}
+
+// authenticate проверяет имя и пароль пользователя из БД, а если такого нет —
+// тестового пользователя из переменных окружения. Возвращает nil при неверных данных
+// и признак того, что для входа нужен второй фактор.
+func authenticate(ctx context.Context, username, password string) (*auth.Principal, bool, error) {
+	var user models.User
+	err := database.DB.WithContext(ctx).Where("LOWER(username) = LOWER(?)", username).First(&user).Error
+	if err == nil {
+		if !auth.CheckPassword(user.PasswordHash, password) || user.Disabled {
+			return nil, false, nil
+		}
+		return &auth.Principal{UserID: user.Id, Username: user.Username, Role: user.Role}, user.TOTPEnabled, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
 	}
 
+	// Выравниваем время ответа с проверкой существующего пользователя: bcrypt выполняется
+	// при любом промахе по БД, в том числе для тестового пользователя
+	auth.CheckPassword("", password)
+
 	// Получаем тестовые учетные данные из переменных окружения
 	testUsername := viper.GetString("TEST_USERNAME")
 	testPassword := viper.GetString("TEST_PASSWORD")
+	if testUsername == "" {
+		return nil, false, nil
+	}
 
-	// Проверяем учетные данные
-	if req.Username != testUsername || req.Password != testPassword {
-		logger.Logger.Warn("Неверные учетные данные")
-		return c.Status(401).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Unauthorized: Invalid credentials",
-		})
-	}
-
-	// Получаем секретный ключ из переменной окружения
-	jwtSecret := viper.GetString("JWT_SECRET")
-	if jwtSecret == "" {
-		logger.Logger.Error("JWT_SECRET не задан в переменных окружения")
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Internal Server Error: JWT_SECRET is not set",
-		})
-	}
-
-	// Создаем JWT-токен
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": req.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Токен действителен 24 часа
-	})
+	// Проверяем учетные данные за постоянное время
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), []byte(testUsername)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(testPassword)) == 1
+	if !usernameOK || !passwordOK {
+		return nil, false, nil
+	}
+
+	// Роль тестового пользователя; без TEST_ROLE — только чтение
+	role := viper.GetString("TEST_ROLE")
+	if role == "" {
+		role = models.RoleViewer
+	}
+	return &auth.Principal{Username: testUsername, Role: role}, false, nil
+}
+
+// respondWithToken создает сеанс и выдает токен доступа и refresh-токен аутентифицированному пользователю
+func respondWithToken(c *fiber.Ctx, principal *auth.Principal) error {
+	log := logger.Ctx(c)
+
+	refreshToken, err := auth.CreateSession(c.UserContext(), principal, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if err != nil {
+		log.WithError(err).Error("Ошибка создания сеанса")
+		return apperr.New(apperr.CodeInternal)
+	}
 
-	// Подписываем токен
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := auth.IssueAccessToken(*principal)
+	if errors.Is(err, auth.ErrSecretNotSet) {
+		log.Error("JWT_SECRET не задан в переменных окружения")
+		return apperr.New(apperr.CodeInternal)
+	}
 	if err != nil {
-		logger.Logger.WithError(err).Error("Ошибка создания JWT-токена")
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Internal Server Error: Failed to generate token",
-		})
+		log.WithError(err).Error("Ошибка создания JWT-токена")
+		return apperr.New(apperr.CodeInternal)
 	}
 
-	logger.Logger.Info("JWT-токен успешно создан")
+	log.WithFields(logrus.Fields{"username": principal.Username, "session_id": principal.SessionID}).Info("JWT-токен успешно создан")
+	metrics.LoginAttempts.WithLabelValues("success").Inc()
+	auditLoginSuccess(c, principal)
 	return c.JSON(fiber.Map{
-		"Success": true,
-		"Token":   tokenString,
+		"Success":      true,
+		"Token":        tokenString,
+		"RefreshToken": refreshToken,
 	})
 }
+
+// auditLoginSuccess записывает вход в журнал аудита. Ошибка записи вход не прерывает.
+func auditLoginSuccess(c *fiber.Ctx, principal *auth.Principal) {
+	event := audit.FromRequest(c)
+	event.Actor = principal.Username
+	if principal.UserID != 0 {
+		id := principal.UserID
+		event.ActorId = &id
+	}
+	event.Action = models.AuditLogin
+	event.TargetType = models.AuditTargetUser
+	event.TargetId = principal.Username
+	event.After = audit.Snapshot(fiber.Map{"SessionId": principal.SessionID, "MFA": principal.MFA})
+	if err := audit.Log(c.UserContext(), event); err != nil {
+		logger.Ctx(c).WithError(err).Error("Ошибка записи в журнал аудита")
+	}
+}
+
+// auditLoginFailure записывает неудачную попытку входа в журнал аудита
+func auditLoginFailure(c *fiber.Ctx, username, reason string) {
+	event := audit.FromRequest(c)
+	event.Action = models.AuditLoginFailed
+	event.TargetType = models.AuditTargetUser
+	event.TargetId = username
+	event.After = audit.Snapshot(fiber.Map{"Reason": reason})
+	if err := audit.Log(c.UserContext(), event); err != nil {
+		logger.Ctx(c).WithError(err).Error("Ошибка записи в журнал аудита")
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"test/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpenAPISpec отдает спецификацию API
+func OpenAPISpec(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return c.Send(openapi.Spec)
+}
+
+// APIDocs отдает страницу документации API
+func APIDocs(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(openapi.DocsPage)
+}
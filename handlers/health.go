@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"test/health"
+	"test/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+// Healthz сообщает, что процесс жив (liveness probe)
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Status":  health.StatusOK,
+	})
+}
+
+// Readyz проверяет зависимости приложения (readiness probe)
+func Readyz(c *fiber.Ctx) error {
+	timeout := viper.GetDuration("HEALTH_CHECK_TIMEOUT")
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+	defer cancel()
+
+	report := health.Run(ctx)
+	if report.Status != health.StatusOK {
+		logger.Ctx(c).WithField("report", report).Warn("Приложение не готово к обработке запросов")
+		return c.Status(503).JSON(fiber.Map{
+			"Success": false,
+			"Status":  report.Status,
+			"Checks":  report.Checks,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Status":  report.Status,
+		"Checks":  report.Checks,
+	})
+}
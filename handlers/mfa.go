@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"test/apperr"
+	"test/auth"
+	"test/database"
+	"test/i18n"
+	"test/lockout"
+	"test/logger"
+	"test/metrics"
+	"test/models"
+	"test/totp"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// EnrollTOTP создает новый секрет TOTP и возвращает URI для QR-кода.
+// Второй фактор включается только после подтверждения кодом (ConfirmTOTP).
+func EnrollTOTP(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return apperr.New(apperr.CodeMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.WithError(err).Error("Ошибка генерации секрета TOTP")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Model(user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		log.WithError(err).Error("Ошибка сохранения секрета TOTP")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	issuer := viper.GetString("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "News"
+	}
+
+	log.Info("Начато подключение TOTP")
+	return c.JSON(fiber.Map{
+		"Success":         true,
+		"Secret":          secret,
+		"ProvisioningURI": totp.ProvisioningURI(issuer, user.Username, secret),
+	})
+}
+
+// ConfirmTOTP включает TOTP после проверки первого кода и выдает коды восстановления
+func ConfirmTOTP(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Code string `json:"code" validate:"required,max=16"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled || user.TOTPSecret == "" {
+		return apperr.New(apperr.CodeMFANotEnrolling)
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		log.Warn("Неверный код TOTP при подключении")
+		return apperr.New(apperr.CodeMFACodeInvalid)
+	}
+
+	var codes []string
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = auth.GenerateRecoveryCodes(tx, user.Id)
+		return err
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка включения TOTP")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.Info("Двухфакторная аутентификация включена")
+	return c.JSON(fiber.Map{
+		"Success":       true,
+		"Message":       i18n.T(c, "message.mfa_enabled"),
+		"RecoveryCodes": codes,
+	})
+}
+
+// DisableTOTP отключает TOTP после проверки текущего кода
+func DisableTOTP(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Code string `json:"code" validate:"required,max=16"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return apperr.New(apperr.CodeMFANotEnabled)
+	}
+	if _, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep); !ok {
+		log.Warn("Неверный код TOTP при отключении")
+		return apperr.New(apperr.CodeMFACodeInvalid)
+	}
+
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.Id).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка отключения TOTP")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.Warn("Двухфакторная аутентификация отключена")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.mfa_disabled"),
+	})
+}
+
+// LoginMFA второй шаг входа: обменивает токен MFA-запроса и код TOTP
+// (или код восстановления) на токен доступа
+func LoginMFA(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+		Code           string `json:"code" validate:"required_without=RecoveryCode,max=16"`
+		RecoveryCode   string `json:"recovery_code" validate:"max=64"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	challenge, err := auth.ParseActionToken(req.ChallengeToken, auth.PurposeMFAChallenge)
+	if err != nil {
+		log.WithError(err).Warn("Невалидный токен MFA-запроса")
+		return apperr.New(apperr.CodeMFAChallengeInvalid)
+	}
+	// Использованный или отозванный после неверных кодов токен не принимает новых попыток
+	used, err := challenge.Used(c.UserContext())
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки токена MFA-запроса")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if used {
+		log.Warn("Повторное использование токена MFA-запроса")
+		return apperr.New(apperr.CodeMFAChallengeInvalid)
+	}
+
+	var user models.User
+	if err := database.DB.WithContext(c.UserContext()).First(&user, challenge.UserID).Error; err != nil || user.Disabled || !user.TOTPEnabled {
+		log.WithError(err).Warn("Пользователь MFA-запроса недоступен")
+		return apperr.New(apperr.CodeMFAChallengeInvalid)
+	}
+
+	// Подбор кодов ограничивается той же блокировкой, что и подбор пароля
+	userKey := lockout.UserKey(user.Username)
+	wait, err := lockout.Check(c.UserContext(), userKey)
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки блокировки входа")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return apperr.New(apperr.CodeLoginThrottled)
+	}
+
+	valid := false
+	switch {
+	case req.Code != "":
+		if step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep); ok {
+			// Условное обновление не дает принять один код в параллельных запросах
+			result := database.DB.WithContext(c.UserContext()).Model(&models.User{}).
+				Where("id = ? AND totp_last_step < ?", user.Id, step).
+				Update("totp_last_step", step)
+			if result.Error != nil {
+				log.WithError(result.Error).Error("Ошибка сохранения шага TOTP")
+				return apperr.New(apperr.CodeInternal)
+			}
+			valid = result.RowsAffected == 1
+		}
+	case req.RecoveryCode != "":
+		valid, err = auth.UseRecoveryCode(c.UserContext(), database.DB, user.Id, req.RecoveryCode)
+		if err != nil {
+			log.WithError(err).Error("Ошибка проверки кода восстановления")
+			return apperr.New(apperr.CodeInternal)
+		}
+		if valid {
+			log.Warn("Вход выполнен с кодом восстановления")
+		}
+	}
+
+	if !valid {
+		log.Warn("Неверный код второго фактора")
+		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		if err := lockout.RecordFailure(c.UserContext(), userKey, c.IP(), lockout.UserPolicy()); err != nil {
+			log.WithError(err).Error("Ошибка сохранения неудачной попытки входа")
+		}
+		auditLoginFailure(c, user.Username, "invalid_mfa_code")
+		if revokeMFAChallenge(c, challenge) {
+			return apperr.New(apperr.CodeMFAChallengeInvalid)
+		}
+		return apperr.New(apperr.CodeInvalidCredentials)
+	}
+
+	if err := challenge.MarkUsed(c.UserContext()); err != nil {
+		log.WithError(err).Warn("Повторное использование токена MFA-запроса")
+		return apperr.New(apperr.CodeMFAChallengeInvalid)
+	}
+	for _, key := range []string{userKey, lockout.ChallengeKey(challenge.Jti)} {
+		if err := lockout.RecordSuccess(c.UserContext(), key); err != nil {
+			log.WithError(err).Error("Ошибка сброса неудачных попыток входа")
+		}
+	}
+
+	return respondWithToken(c, &auth.Principal{
+		UserID:   user.Id,
+		Username: user.Username,
+		Role:     user.Role,
+		MFA:      true,
+	})
+}
+
+// revokeMFAChallenge учитывает неверный код для токена MFA-запроса и после
+// MFA_MAX_FAILURES ошибок помечает токен использованным: дальнейший подбор, в том числе
+// с других IP-адресов, требует нового входа по паролю. Возвращает true, если токен отозван.
+func revokeMFAChallenge(c *fiber.Ctx, challenge *auth.ActionToken) bool {
+	log := logger.Ctx(c)
+	ctx := c.UserContext()
+	key := lockout.ChallengeKey(challenge.Jti)
+	policy := lockout.ChallengePolicy()
+
+	if err := lockout.RecordFailure(ctx, key, c.IP(), policy); err != nil {
+		log.WithError(err).Error("Ошибка сохранения неудачной попытки входа")
+		return false
+	}
+	failures, err := lockout.Failures(ctx, key)
+	if err != nil {
+		log.WithError(err).Error("Ошибка чтения неудачных попыток входа")
+		return false
+	}
+	if policy.MaxFailures <= 0 || failures < policy.MaxFailures {
+		return false
+	}
+
+	if err := challenge.MarkUsed(ctx); err != nil && !errors.Is(err, auth.ErrTokenUsed) {
+		log.WithError(err).Error("Ошибка отзыва токена MFA-запроса")
+		return false
+	}
+	if err := lockout.RecordSuccess(ctx, key); err != nil {
+		log.WithError(err).Error("Ошибка сброса неудачных попыток входа")
+	}
+	log.WithField("failures", failures).Warn("Токен MFA-запроса отозван после неверных кодов")
+	return true
+}
+
+// respondWithMFAChallenge первый шаг входа для пользователя с TOTP:
+// вместо токена доступа выдается короткоживущий токен MFA-запроса
+func respondWithMFAChallenge(c *fiber.Ctx, principal *auth.Principal) error {
+	log := logger.Ctx(c)
+
+	challenge, err := auth.IssueActionToken(auth.PurposeMFAChallenge, principal.UserID, tokenTTL("MFA_CHALLENGE_TTL", 5*time.Minute))
+	if err != nil {
+		log.WithError(err).Error("Ошибка создания токена MFA-запроса")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("username", principal.Username).Info("Требуется второй фактор")
+	return c.JSON(fiber.Map{
+		"Success":        true,
+		"MfaRequired":    true,
+		"ChallengeToken": challenge,
+	})
+}
+
+// currentUser загружает учетную запись текущего пользователя из БД.
+// Если пользователь не найден, возвращает ошибку приложения.
+func currentUser(c *fiber.Ctx) (*models.User, error) {
+	principal := auth.PrincipalFrom(c)
+	if principal == nil || principal.UserID == 0 {
+		return nil, apperr.New(apperr.CodeRegisteredUserOnly)
+	}
+
+	var user models.User
+	err := database.DB.WithContext(c.UserContext()).First(&user, principal.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperr.New(apperr.CodeUserNotFound)
+	}
+	if err != nil {
+		logger.Ctx(c).WithError(err).Error("Ошибка поиска пользователя")
+		return nil, apperr.New(apperr.CodeInternal)
+	}
+	return &user, nil
+}
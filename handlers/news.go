@@ -1,347 +1,285 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
+
+	"test/apperr"
+	"test/audit"
 	"test/database"
+	"test/i18n"
 	"test/logger"
+	"test/metrics"
 	"test/models"
+	"test/newsdb"
+	"test/tracing"
+	"test/validation"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 func EditNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
 	// Извлекаем параметр Id из маршрута
 	newsIDStr := c.Params("Id")
 
-	logger.Logger.WithField("news_id", newsIDStr).Debug("Получен ID новости для редактирования")
+	log.WithField("news_id", newsIDStr).Debug("Получен ID новости для редактирования")
 
 	// Преобразуем строку в uint
 	newsIDUint64, err := strconv.ParseUint(newsIDStr, 10, 64)
 	if err != nil {
-		logger.Logger.WithError(err).Warn("Неверный формат ID новости")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Неверный формат ID новости",
-		})
+		log.WithError(err).Warn("Неверный формат ID новости")
+		return apperr.New(apperr.CodeInvalidID)
 	}
 
 	// Преобразуем uint64 в uint
 	newsID := uint(newsIDUint64)
 
-	var req models.NewsResponse
+	var req models.UpdateNewsRequest
 
-	// Парсим тело запроса
-	if err := c.BodyParser(&req); err != nil {
-		logger.Logger.WithError(err).Warn("Ошибка парсинга тела запроса")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Неверный формат запроса",
-		})
+	// Парсим и проверяем тело запроса
+	if err := validation.Body(c, &req); err != nil {
+		return err
 	}
 
-	logger.Logger.WithFields(logrus.Fields{
-		"title":      req.Title,
-		"content":    req.Content,
-		"categories": req.Categories,
-	}).Info("Тело запроса успешно распарсено")
-
-	// Валидация полей
-	if req.Title == "" || req.Content == "" {
-		logger.Logger.Warn("Заголовок или содержимое новости пустые")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Заголовок и содержимое обязательны",
-		})
-	}
+	log.WithFields(logrus.Fields{
+		"title":          req.Title,
+		"content_length": len(req.Content),
+		"categories":     req.Categories,
+	}).Debug("Тело запроса успешно распарсено")
 
 	// Начинаем транзакцию
-	tx := database.DB.Begin()
+	ctx, span := tracing.Tracer().Start(c.UserContext(), "EditNews transaction")
+	defer span.End()
+
+	tx := audit.Begin(database.DB.WithContext(ctx))
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Logger.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
+			log.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
 			tx.Rollback()
 		}
 	}()
 
-	// Обновляем новость
-	if err := tx.Model(&models.News{}).Where("id = ?", newsID).Updates(models.News{
-		Title:   req.Title,
-		Content: req.Content,
-	}).Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка обновления новости")
-		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка обновления новости",
-		})
+	change, err := editNews(c, tx, newsID, &req)
+	if err == nil {
+		if err = change.record(c, tx); err != nil {
+			log.WithError(err).Error("Ошибка записи в журнал аудита")
+		}
 	}
-
-	logger.Logger.WithField("news_id", newsID).Info("Новость успешно обновлена")
-
-	// Удаляем старые категории
-	if err := tx.Where("news_id = ?", newsID).Delete(&models.NewsCategory{}).Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка удаления старых категорий")
+	if err != nil {
 		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка удаления категорий",
-		})
+		return newsWriteError(err)
 	}
 
-	logger.Logger.WithField("news_id", newsID).Info("Старые категории успешно удалены")
-
-	// Добавляем новые категории
-	for _, categoryID := range req.Categories {
-		if err := tx.Create(&models.NewsCategory{
-			NewsId:     newsID,
-			CategoryId: categoryID,
-		}).Error; err != nil {
-			logger.Logger.WithFields(logrus.Fields{
-				"news_id":     newsID,
-				"category_id": categoryID,
-				"error":       err,
-			}).Error("Ошибка сохранения категории")
-			tx.Rollback()
-			return c.Status(500).JSON(fiber.Map{
-				"Success": false,
-				"Message": "Ошибка сохранения категории",
-			})
-		}
-	}
-
-	logger.Logger.WithField("news_id", newsID).Info("Новые категории успешно добавлены")
-
 	// Фиксируем транзакцию
-	if err := tx.Commit().Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка фиксации транзакции")
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка фиксации транзакции",
-		})
+	if err := audit.Commit(tx); err != nil {
+		log.WithError(err).Error("Ошибка фиксации транзакции")
+		return apperr.New(apperr.CodeInternal)
 	}
 
-	logger.Logger.WithField("news_id", newsID).Info("Транзакция успешно зафиксирована")
+	log.WithField("news_id", newsID).Info("Транзакция успешно зафиксирована")
+	metrics.NewsEdited.Inc()
 	return c.JSON(fiber.Map{
-		"Success": true,
-		"Message": "Новость успешно обновлена",
+		"Success":       true,
+		"Message":       i18n.T(c, "message.news_updated"),
+		"SchemaVersion": models.NewsSchemaVersion,
+		"News":          change.After,
 	})
 }
 
 func GetNewsList(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
 	// Пагинация
 	page := c.QueryInt("page", 1)
 	limit := c.QueryInt("limit", 10)
 	offset := (page - 1) * limit
 
-	logger.Logger.WithFields(logrus.Fields{
+	log.WithFields(logrus.Fields{
 		"page":  page,
 		"limit": limit,
 	}).Info("Запрос списка новостей")
 
-	// Инициализируем массив новостей
-	var newsList []models.NewsResponse
+	// Страница новостей, затем категории и авторы для них
+	db := database.DB.WithContext(c.UserContext())
+	var newsList []models.News
+	err := db.Order("id").Limit(limit).Offset(offset).Find(&newsList).Error
+
+	var result []models.NewsView
+	if err == nil {
+		result, err = newsdb.Views(db, newsList)
+	}
+	if err != nil {
+		log.Errorf("Ошибка выполнения запроса к базе данных: %v", err)
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("count", len(result)).Info("Новости успешно получены")
+	return c.JSON(fiber.Map{
+		"Success":       true,
+		"SchemaVersion": models.NewsSchemaVersion,
+		"News":          result,
+	})
+}
 
-	// Выполняем запрос с использованием GORM
-	err := database.DB.Model(&models.News{}).
-		Select("news.id, news.title, news.content, news_categories.category_id").
-		Joins("LEFT JOIN news_categories ON news.id = news_categories.news_id").
-		Order("news.id").
-		Limit(limit).
-		Offset(offset).
-		Scan(&newsList).Error
+// GetNews возвращает новость с категориями и авторами
+func GetNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
 
+	newsID, err := strconv.ParseUint(c.Params("Id"), 10, 64)
 	if err != nil {
-		logger.Logger.Errorf("Ошибка выполнения запроса к базе данных: %v", err)
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка выполнения запроса к базе данных",
-		})
+		log.WithError(err).Warn("Неверный формат ID новости")
+		return apperr.New(apperr.CodeInvalidID)
 	}
 
-	// Группируем данные по ID новости
-	newsMap := make(map[uint]*models.NewsResponse)
-	for _, news := range newsList {
-		if _, exists := newsMap[news.Id]; !exists {
-			newsMap[news.Id] = &models.NewsResponse{
-				Id:         news.Id,
-				Title:      news.Title,
-				Content:    news.Content,
-				Categories: []uint{},
-			}
-		}
-		if news.Categories != nil {
-			newsMap[news.Id].Categories = append(newsMap[news.Id].Categories, news.Categories...)
-		}
+	db := database.DB.WithContext(c.UserContext())
+	var news models.News
+	if err := db.First(&news, newsID).Error; err != nil {
+		return newsLookupError(c, err)
 	}
 
-	var result []models.NewsResponse
-	for _, news := range newsMap {
-		result = append(result, *news)
+	result, err := newsdb.Views(db, []models.News{news})
+	if err != nil {
+		log.Errorf("Ошибка выполнения запроса к базе данных: %v", err)
+		return apperr.New(apperr.CodeInternal)
 	}
 
-	logger.Logger.WithField("count", len(result)).Info("Новости успешно получены")
 	return c.JSON(fiber.Map{
-		"Success": true,
-		"News":    result,
+		"Success":       true,
+		"SchemaVersion": models.NewsSchemaVersion,
+		"News":          result[0],
 	})
 }
 
-func CreateNews(c *fiber.Ctx) error {
-	var req models.NewsResponse
-
-	// Парсим тело запроса
-	if err := c.BodyParser(&req); err != nil {
-		logger.Logger.WithError(err).Warn("Ошибка парсинга тела запроса")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Неверный формат запроса",
-		})
+// newsLookupError приводит ошибку поиска новости к news_not_found, если ее нет, иначе к internal_error
+func newsLookupError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.New(apperr.CodeNewsNotFound)
 	}
+	logger.Ctx(c).WithError(err).Error("Ошибка получения новости")
+	return apperr.New(apperr.CodeInternal)
+}
+
+func CreateNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
 
-	logger.Logger.WithFields(logrus.Fields{
-		"title":      req.Title,
-		"content":    req.Content,
-		"categories": req.Categories,
-	}).Info("Тело запроса успешно распарсено")
-
-	// Валидация полей
-	if req.Title == "" || req.Content == "" {
-		logger.Logger.Warn("Заголовок или содержимое новости пустые")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Заголовок и содержимое обязательны",
-		})
+	var req models.CreateNewsRequest
+
+	// Парсим и проверяем тело запроса
+	if err := validation.Body(c, &req); err != nil {
+		return err
 	}
 
+	log.WithFields(logrus.Fields{
+		"title":          req.Title,
+		"content_length": len(req.Content),
+		"categories":     req.Categories,
+	}).Debug("Тело запроса успешно распарсено")
+
 	// Начинаем транзакцию
-	tx := database.DB.Begin()
+	ctx, span := tracing.Tracer().Start(c.UserContext(), "CreateNews transaction")
+	defer span.End()
+
+	tx := audit.Begin(database.DB.WithContext(ctx))
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Logger.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
+			log.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
 			tx.Rollback()
 		}
 	}()
 
-	// Создаем новость
-	news := models.News{
-		Title:   req.Title,
-		Content: req.Content,
+	change, err := createNews(c, tx, &req)
+	if err == nil {
+		if err = change.record(c, tx); err != nil {
+			log.WithError(err).Error("Ошибка записи в журнал аудита")
+		}
 	}
-
-	if err := tx.Create(&news).Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка создания новости")
+	if err != nil {
 		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка создания новости",
-		})
-	}
-
-	logger.Logger.WithField("news_id", news.Id).Info("Новость успешно создана")
-
-	// Добавляем категории
-	for _, categoryID := range req.Categories {
-		if err := tx.Create(&models.NewsCategory{
-			NewsId:     news.Id,
-			CategoryId: categoryID,
-		}).Error; err != nil {
-			logger.Logger.WithFields(logrus.Fields{
-				"news_id":     news.Id,
-				"category_id": categoryID,
-				"error":       err,
-			}).Error("Ошибка сохранения категории")
-			tx.Rollback()
-			return c.Status(500).JSON(fiber.Map{
-				"Success": false,
-				"Message": "Ошибка сохранения категории",
-			})
-		}
+		return newsWriteError(err)
 	}
 
-	logger.Logger.WithField("news_id", news.Id).Info("Категории успешно добавлены")
-
 	// Фиксируем транзакцию
-	if err := tx.Commit().Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка фиксации транзакции")
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка фиксации транзакции",
-		})
+	if err := audit.Commit(tx); err != nil {
+		log.WithError(err).Error("Ошибка фиксации транзакции")
+		return apperr.New(apperr.CodeInternal)
 	}
 
-	logger.Logger.WithField("news_id", news.Id).Info("Транзакция успешно зафиксирована")
+	log.WithField("news_id", change.NewsId).Info("Транзакция успешно зафиксирована")
+	metrics.NewsCreated.Inc()
 	return c.JSON(fiber.Map{
-		"Success": true,
-		"Message": "Новость успешно создана",
-		"NewsId":  news.Id,
+		"Success":       true,
+		"Message":       i18n.T(c, "message.news_created"),
+		"NewsId":        change.NewsId,
+		"SchemaVersion": models.NewsSchemaVersion,
+		"News":          change.After,
 	})
 }
 
 func DeleteNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
 	// Извлекаем параметр Id из маршрута
 	newsIDStr := c.Params("Id")
 
-	logger.Logger.WithField("news_id", newsIDStr).Debug("Получен ID новости для удаления")
+	log.WithField("news_id", newsIDStr).Debug("Получен ID новости для удаления")
 
 	// Преобразуем строку в uint
 	newsIDUint64, err := strconv.ParseUint(newsIDStr, 10, 64)
 	if err != nil {
-		logger.Logger.WithError(err).Warn("Неверный формат ID новости")
-		return c.Status(400).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Неверный формат ID новости",
-		})
+		log.WithError(err).Warn("Неверный формат ID новости")
+		return apperr.New(apperr.CodeInvalidID)
 	}
 
 	// Преобразуем uint64 в uint
 	newsID := uint(newsIDUint64)
 
 	// Начинаем транзакцию
-	tx := database.DB.Begin()
+	ctx, span := tracing.Tracer().Start(c.UserContext(), "DeleteNews transaction")
+	defer span.End()
+
+	tx := audit.Begin(database.DB.WithContext(ctx))
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Logger.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
+			log.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
 			tx.Rollback()
 		}
 	}()
 
-	// Удаляем связанные категории
-	if err := tx.Where("news_id = ?", newsID).Delete(&models.NewsCategory{}).Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка удаления категорий")
-		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка удаления категорий",
-		})
+	change, err := deleteNews(c, tx, newsID)
+	if err == nil {
+		if err = change.record(c, tx); err != nil {
+			log.WithError(err).Error("Ошибка записи в журнал аудита")
+		}
 	}
-
-	logger.Logger.WithField("news_id", newsID).Info("Категории успешно удалены")
-
-	// Удаляем новость
-	if err := tx.Delete(&models.News{}, newsID).Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка удаления новости")
+	if err != nil {
 		tx.Rollback()
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка удаления новости",
-		})
+		return newsWriteError(err)
 	}
 
-	logger.Logger.WithField("news_id", newsID).Info("Новость успешно удалена")
-
 	// Фиксируем транзакцию
-	if err := tx.Commit().Error; err != nil {
-		logger.Logger.WithError(err).Error("Ошибка фиксации транзакции")
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Ошибка фиксации транзакции",
-		})
+	if err := audit.Commit(tx); err != nil {
+		log.WithError(err).Error("Ошибка фиксации транзакции")
+		return apperr.New(apperr.CodeInternal)
 	}
 
-	logger.Logger.WithField("news_id", newsID).Info("Транзакция успешно зафиксирована")
+	log.WithField("news_id", newsID).Info("Транзакция успешно зафиксирована")
+	metrics.NewsDeleted.Inc()
 	return c.JSON(fiber.Map{
 		"Success": true,
-		"Message": "Новость успешно удалена",
+		"Message": i18n.T(c, "message.news_deleted"),
 	})
 }
+
+// newsWriteError возвращает ошибки приложения как есть, прочие ошибки изменения
+// новости (уже записанные в лог) приводит к internal_error
+func newsWriteError(err error) *apperr.Error {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return apperr.Wrap(err, apperr.CodeInternal)
+}
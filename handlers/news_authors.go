@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"test/auth"
+	"test/database"
+	"test/models"
+
+	"gorm.io/gorm"
+)
+
+var errUnknownCoauthor = errors.New("unknown co-author")
+
+// userIDOf возвращает идентификатор пользователя для ссылок на users или nil для пользователей не из БД
+func userIDOf(p *auth.Principal) *uint {
+	if p.UserID == 0 {
+		return nil
+	}
+	id := p.UserID
+	return &id
+}
+
+// normalizeCoauthors убирает повторы и автора из списка соавторов и проверяет, что пользователи существуют
+func normalizeCoauthors(ctx context.Context, ids []uint, authorID *uint) ([]uint, error) {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] || (authorID != nil && *authorID == id) {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.User{}).Where("id IN ?", result).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if int(count) != len(result) {
+		return nil, errUnknownCoauthor
+	}
+	return result, nil
+}
+
+// replaceCoauthors заменяет соавторов новости
+func replaceCoauthors(tx *gorm.DB, newsID uint, ids []uint) error {
+	if err := tx.Where("news_id = ?", newsID).Delete(&models.NewsCoauthor{}).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows := make([]models.NewsCoauthor, len(ids))
+	for i, id := range ids {
+		rows[i] = models.NewsCoauthor{NewsId: newsID, UserId: id}
+	}
+	return tx.Create(&rows).Error
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"test/apperr"
+	"test/audit"
+	"test/database"
+	"test/i18n"
+	"test/logger"
+	"test/metrics"
+	"test/models"
+	"test/tracing"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// batchResult результат одной операции пакета
+type batchResult struct {
+	Index  int              `json:"Index"`
+	Op     string           `json:"Op"`
+	Id     uint             `json:"Id,omitempty"`
+	Status int              `json:"Status"`
+	News   *models.NewsView `json:"News,omitempty"`
+	Error  *apperr.Problem  `json:"Error,omitempty"`
+}
+
+// BatchNews выполняет список операций над новостями в одной транзакции, каждую —
+// в своей точке сохранения. В режиме atomic первая ошибка отменяет весь пакет,
+// в режиме best_effort отменяется только неудачная операция. События журнала аудита
+// записываются после всех операций, перед фиксацией.
+func BatchNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req models.NewsBatchRequest
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+	if req.Mode == "" {
+		req.Mode = models.BatchAtomic
+	}
+
+	log.WithFields(logrus.Fields{
+		"mode":       req.Mode,
+		"operations": len(req.Operations),
+	}).Debug("Тело запроса успешно распарсено")
+
+	// Начинаем транзакцию
+	ctx, span := tracing.Tracer().Start(c.UserContext(), "BatchNews transaction")
+	defer span.End()
+
+	tx := audit.Begin(database.DB.WithContext(ctx))
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("error", r).Error("Произошла ошибка, выполняется откат транзакции")
+			tx.Rollback()
+		}
+	}()
+
+	lang := i18n.Lang(c)
+	results := make([]batchResult, 0, len(req.Operations))
+	changes := make([]*newsChange, 0, len(req.Operations))
+	failed := 0
+	for i, op := range req.Operations {
+		result := batchResult{Index: i, Op: op.Op, Id: op.Id, Status: fiber.StatusOK}
+		var change *newsChange
+		err := tx.Transaction(func(sp *gorm.DB) error {
+			var err error
+			switch op.Op {
+			case models.BatchOpCreate:
+				change, err = createNews(c, sp, op.Create)
+			case models.BatchOpUpdate:
+				change, err = editNews(c, sp, op.Id, op.Update)
+			case models.BatchOpDelete:
+				change, err = deleteNews(c, sp, op.Id)
+			}
+			return err
+		})
+		if err != nil {
+			problem := newsWriteError(err).Problem(lang)
+			result.Status, result.Error = problem.Status, &problem
+			failed++
+		} else {
+			changes = append(changes, change)
+			result.Id, result.News = change.NewsId, change.After
+		}
+		results = append(results, result)
+
+		if err != nil && req.Mode == models.BatchAtomic {
+			break
+		}
+	}
+
+	// В режиме atomic при ошибке отменяются и уже выполненные операции
+	if failed > 0 && req.Mode == models.BatchAtomic {
+		tx.Rollback()
+		log.WithField("operation", len(results)-1).Warn("Пакет отменен из-за ошибки операции")
+
+		aborted := apperr.New(apperr.CodeBatchAborted).Problem(lang)
+		failedIndex := len(results) - 1
+		for i := range results[:failedIndex] {
+			results[i].Status, results[i].News, results[i].Error = aborted.Status, nil, &aborted
+			if results[i].Op == models.BatchOpCreate {
+				results[i].Id = 0
+			}
+		}
+		for _, op := range req.Operations[failedIndex+1:] {
+			results = append(results, batchResult{Index: len(results), Op: op.Op, Id: op.Id, Status: aborted.Status, Error: &aborted})
+		}
+		return c.JSON(fiber.Map{
+			"Success":       true,
+			"Mode":          req.Mode,
+			"Committed":     false,
+			"Succeeded":     0,
+			"Failed":        len(results),
+			"SchemaVersion": models.NewsSchemaVersion,
+			"Results":       results,
+		})
+	}
+
+	// Записываем выполненные операции в журнал аудита
+	for _, change := range changes {
+		if err := change.record(c, tx); err != nil {
+			log.WithError(err).WithField("news_id", change.NewsId).Error("Ошибка записи в журнал аудита")
+			tx.Rollback()
+			return apperr.New(apperr.CodeInternal)
+		}
+	}
+
+	// Фиксируем транзакцию
+	if err := audit.Commit(tx); err != nil {
+		log.WithError(err).Error("Ошибка фиксации транзакции")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		switch result.Op {
+		case models.BatchOpCreate:
+			metrics.NewsCreated.Inc()
+		case models.BatchOpUpdate:
+			metrics.NewsEdited.Inc()
+		case models.BatchOpDelete:
+			metrics.NewsDeleted.Inc()
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+	}).Info("Пакет новостей зафиксирован")
+	return c.JSON(fiber.Map{
+		"Success":       true,
+		"Mode":          req.Mode,
+		"Committed":     true,
+		"Succeeded":     len(results) - failed,
+		"Failed":        failed,
+		"SchemaVersion": models.NewsSchemaVersion,
+		"Results":       results,
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/i18n"
+	"test/logger"
+	"test/models"
+	"test/newsio"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ExportNews выгружает все новости с категориями в формате json (по умолчанию), ndjson или csv.
+// Ответ пишется потоком, пачками новостей.
+func ExportNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	name := utils.CopyString(c.Query("format", string(newsio.FormatJSON)))
+	format, err := newsio.ParseFormat(name)
+	if err != nil {
+		return apperr.New(apperr.CodeValidationFailed).WithDetail("detail.unknown_export_format", name)
+	}
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="news.`+string(format)+`"`)
+
+	// Ответ пишется частями после выхода из обработчика, поэтому контекст запроса не используется
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		count, err := newsio.Export(context.Background(), w, format)
+		if err != nil {
+			log.WithError(err).Error("Ошибка выгрузки новостей")
+			return
+		}
+		log.WithField("count", count).Info("Новости выгружены")
+	})
+	return nil
+}
+
+// ImportNews загружает новости из тела запроса. Формат задается параметром format или
+// по Content-Type; dry_run=true проверяет файл без сохранения. Тело читается потоком.
+func ImportNews(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	format, err := importFormat(c)
+	if err != nil {
+		return err
+	}
+
+	principal := auth.PrincipalFrom(c)
+	opts := newsio.Options{
+		DryRun:    c.QueryBool("dry_run"),
+		Importer:  models.NewsAuthor{Id: userIDOf(principal), Username: principal.Username},
+		Principal: principal,
+		Event:     audit.FromRequest(c),
+	}
+
+	var body io.Reader = bytes.NewReader(c.Body())
+	if c.Request().IsBodyStream() {
+		body = c.Context().RequestBodyStream()
+	}
+
+	report, err := newsio.Import(c.UserContext(), body, format, opts)
+	var fileErr *newsio.FileError
+	if errors.As(err, &fileErr) {
+		log.WithError(err).Warn("Файл загрузки новостей не читается")
+		return apperr.Wrap(err, apperr.CodeBadRequest).
+			WithDetail("detail.import_aborted", fileErr.Record, report.Created, report.Updated)
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка загрузки новостей")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithFields(logrus.Fields{
+		"total":   report.Total,
+		"created": report.Created,
+		"updated": report.Updated,
+		"failed":  report.Failed,
+		"dry_run": report.DryRun,
+	}).Info("Новости загружены")
+
+	report.Localize(i18n.Lang(c))
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Report":  report,
+	})
+}
+
+// importFormat формат загрузки из параметра format, иначе по Content-Type; по умолчанию JSON
+func importFormat(c *fiber.Ctx) (newsio.Format, error) {
+	name := utils.CopyString(c.Query("format"))
+	if name == "" {
+		contentType := utils.ToLower(string(c.Request().Header.ContentType()))
+		switch {
+		case strings.HasPrefix(contentType, "text/csv"):
+			return newsio.FormatCSV, nil
+		case strings.HasPrefix(contentType, "application/x-ndjson"):
+			return newsio.FormatNDJSON, nil
+		}
+		return newsio.FormatJSON, nil
+	}
+
+	format, err := newsio.ParseFormat(name)
+	if err != nil {
+		return "", apperr.New(apperr.CodeValidationFailed).WithDetail("detail.unknown_import_format", name)
+	}
+	return format, nil
+}
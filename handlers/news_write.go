@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/logger"
+	"test/models"
+	"test/newsdb"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Изменения новостей в транзакции tx, общие для отдельных запросов и пакетной записи.
+// Отказы возвращаются как *apperr.Error, прочие ошибки записываются в лог и
+// возвращаются как есть; откат транзакции выполняет вызывающий. Событие журнала аудита
+// вызывающий записывает по возвращенному newsChange: пакет — после всех операций.
+
+// newsChange изменение новости для журнала аудита: состояние до и после
+type newsChange struct {
+	Action string
+	NewsId uint
+	Before *models.NewsView
+	After  *models.NewsView
+}
+
+// record записывает изменение в журнал аудита в транзакции tx
+func (ch *newsChange) record(c *fiber.Ctx, tx *gorm.DB) error {
+	return newsdb.RecordAudit(tx, audit.FromRequest(c), ch.Action, ch.NewsId, ch.Before, ch.After)
+}
+
+// createNews создает новость от имени текущего пользователя с категориями и соавторами
+func createNews(c *fiber.Ctx, tx *gorm.DB, req *models.CreateNewsRequest) (*newsChange, error) {
+	log := logger.Ctx(c)
+
+	// Автором становится текущий пользователь
+	principal := auth.PrincipalFrom(c)
+	authorID := userIDOf(principal)
+
+	coauthors, err := normalizeCoauthors(tx.Statement.Context, req.CoAuthorIds, authorID)
+	if errors.Is(err, errUnknownCoauthor) {
+		return nil, apperr.New(apperr.CodeValidationFailed).WithDetail("detail.coauthor_not_found")
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки соавторов")
+		return nil, err
+	}
+
+	// Создаем новость
+	news := req.News()
+	news.AuthorId = authorID
+	news.AuthorName = principal.Username
+
+	if err := tx.Create(&news).Error; err != nil {
+		log.WithError(err).Error("Ошибка создания новости")
+		return nil, err
+	}
+
+	log.WithField("news_id", news.Id).Info("Новость успешно создана")
+
+	// Добавляем категории
+	if err := newsdb.SetCategories(tx, news.Id, req.Categories); err != nil {
+		log.WithFields(logrus.Fields{
+			"news_id":    news.Id,
+			"categories": req.Categories,
+			"error":      err,
+		}).Error("Ошибка сохранения категорий")
+		return nil, err
+	}
+
+	log.WithField("news_id", news.Id).Info("Категории успешно добавлены")
+
+	// Добавляем соавторов
+	if err := replaceCoauthors(tx, news.Id, coauthors); err != nil {
+		log.WithError(err).Error("Ошибка сохранения соавторов")
+		return nil, err
+	}
+
+	after, err := newsdb.Snapshot(tx, news.Id)
+	if err != nil {
+		log.WithError(err).Error("Ошибка получения новости")
+		return nil, err
+	}
+	return &newsChange{Action: models.AuditNewsCreate, NewsId: news.Id, After: after}, nil
+}
+
+// editNews изменяет новость, если у текущего пользователя есть на это права
+func editNews(c *fiber.Ctx, tx *gorm.DB, newsID uint, req *models.UpdateNewsRequest) (*newsChange, error) {
+	log := logger.Ctx(c)
+	principal := auth.PrincipalFrom(c)
+
+	// Загружаем новость и проверяем права на редактирование
+	var news models.News
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&news, newsID).Error; err != nil {
+		return nil, newsLookupError(c, err)
+	}
+	allowed, err := newsdb.CanEdit(tx, &news, principal)
+	if err != nil {
+		log.WithError(err).Error("Ошибка проверки прав на новость")
+		return nil, err
+	}
+	if !allowed {
+		log.WithField("news_id", newsID).Warn("Нет прав на редактирование новости")
+		return nil, apperr.New(apperr.CodeNewsForbidden).WithDetail("detail.news_edit_forbidden")
+	}
+
+	// Состояние до изменения для журнала аудита
+	before, err := newsdb.Snapshot(tx, newsID)
+	if err != nil {
+		log.WithError(err).Error("Ошибка получения новости")
+		return nil, err
+	}
+
+	// Соавторов меняют только автор и роли с правом редактирования всех новостей
+	if req.CoAuthorIds != nil {
+		if !newsdb.EditorRole(principal.Role) && !newsdb.IsAuthor(&news, principal) {
+			return nil, apperr.New(apperr.CodeNewsForbidden).WithDetail("detail.news_coauthors_forbidden")
+		}
+
+		coauthors, err := normalizeCoauthors(tx.Statement.Context, *req.CoAuthorIds, news.AuthorId)
+		if errors.Is(err, errUnknownCoauthor) {
+			return nil, apperr.New(apperr.CodeValidationFailed).WithDetail("detail.coauthor_not_found")
+		}
+		if err == nil {
+			err = replaceCoauthors(tx, newsID, coauthors)
+		}
+		if err != nil {
+			log.WithError(err).Error("Ошибка сохранения соавторов")
+			return nil, err
+		}
+	}
+
+	// Обновляем новость
+	changes := req.Changes()
+	changes["last_editor_id"] = userIDOf(principal)
+	changes["last_editor_name"] = principal.Username
+	if err := tx.Model(&models.News{}).Where("id = ?", newsID).Updates(changes).Error; err != nil {
+		log.WithError(err).Error("Ошибка обновления новости")
+		return nil, err
+	}
+
+	log.WithField("news_id", newsID).Info("Новость успешно обновлена")
+
+	// Заменяем категории
+	if err := tx.Where("news_id = ?", newsID).Delete(&models.NewsCategory{}).Error; err != nil {
+		log.WithError(err).Error("Ошибка удаления старых категорий")
+		return nil, err
+	}
+	if err := newsdb.SetCategories(tx, newsID, req.Categories); err != nil {
+		log.WithFields(logrus.Fields{
+			"news_id":    newsID,
+			"categories": req.Categories,
+			"error":      err,
+		}).Error("Ошибка сохранения категорий")
+		return nil, err
+	}
+
+	log.WithField("news_id", newsID).Info("Категории успешно обновлены")
+
+	after, err := newsdb.Snapshot(tx, newsID)
+	if err != nil {
+		log.WithError(err).Error("Ошибка получения новости")
+		return nil, err
+	}
+	return &newsChange{Action: models.AuditNewsEdit, NewsId: newsID, Before: before, After: after}, nil
+}
+
+// deleteNews удаляет новость с категориями и соавторами. Удалить новость могут
+// только автор и роли с правом редактирования всех новостей.
+func deleteNews(c *fiber.Ctx, tx *gorm.DB, newsID uint) (*newsChange, error) {
+	log := logger.Ctx(c)
+
+	var news models.News
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&news, newsID).Error; err != nil {
+		return nil, newsLookupError(c, err)
+	}
+	if principal := auth.PrincipalFrom(c); !newsdb.EditorRole(principal.Role) && !newsdb.IsAuthor(&news, principal) {
+		log.WithField("news_id", newsID).Warn("Нет прав на удаление новости")
+		return nil, apperr.New(apperr.CodeNewsForbidden).WithDetail("detail.news_delete_forbidden")
+	}
+
+	// Последнее состояние новости для журнала аудита
+	before, err := newsdb.Snapshot(tx, newsID)
+	if err != nil {
+		log.WithError(err).Error("Ошибка получения новости")
+		return nil, err
+	}
+
+	// Удаляем соавторов
+	if err := tx.Where("news_id = ?", newsID).Delete(&models.NewsCoauthor{}).Error; err != nil {
+		log.WithError(err).Error("Ошибка удаления соавторов")
+		return nil, err
+	}
+
+	// Удаляем связанные категории
+	if err := tx.Where("news_id = ?", newsID).Delete(&models.NewsCategory{}).Error; err != nil {
+		log.WithError(err).Error("Ошибка удаления категорий")
+		return nil, err
+	}
+
+	log.WithField("news_id", newsID).Info("Категории успешно удалены")
+
+	// Удаляем новость
+	if err := tx.Delete(&models.News{}, newsID).Error; err != nil {
+		log.WithError(err).Error("Ошибка удаления новости")
+		return nil, err
+	}
+
+	log.WithField("news_id", newsID).Info("Новость успешно удалена")
+	return &newsChange{Action: models.AuditNewsDelete, NewsId: newsID, Before: before}, nil
+}
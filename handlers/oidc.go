@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/logger"
+	"test/metrics"
+	"test/models"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// OIDCLogin перенаправляет пользователя к OIDC-провайдеру (authorization code + PKCE)
+func OIDCLogin(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	client, err := auth.OIDC(c.UserContext())
+	if errors.Is(err, auth.ErrOIDCNotConfigured) {
+		return apperr.New(apperr.CodeOIDCNotConfigured)
+	}
+	if err != nil {
+		log.WithError(err).Error("OIDC-провайдер недоступен")
+		return apperr.New(apperr.CodeProviderUnavailable)
+	}
+
+	state := auth.OIDCState{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+	signed, err := auth.IssueOIDCState(state, oidcStateTTL)
+	if err != nil {
+		log.WithError(err).Error("Ошибка подписи состояния входа OIDC")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    signed,
+		Path:     "/api/oidc",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(client.OAuth2.AuthCodeURL(state.State,
+		oauth2.SetAuthURLParam("nonce", state.Nonce),
+		oauth2.S256ChallengeOption(state.Verifier),
+	), fiber.StatusFound)
+}
+
+// OIDCCallback обрабатывает возврат от провайдера: обменивает код на токены,
+// проверяет ID-токен по JWKS провайдера и выдает собственный токен доступа
+func OIDCCallback(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+	ctx := c.UserContext()
+
+	unauthorized := func(reason string, err error) error {
+		log.WithError(err).Warn(reason)
+		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		return apperr.New(apperr.CodeOIDCLoginFailed)
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		return unauthorized("OIDC-провайдер вернул ошибку", errors.New(providerErr+": "+c.Query("error_description")))
+	}
+
+	client, err := auth.OIDC(ctx)
+	if errors.Is(err, auth.ErrOIDCNotConfigured) {
+		return apperr.New(apperr.CodeOIDCNotConfigured)
+	}
+	if err != nil {
+		log.WithError(err).Error("OIDC-провайдер недоступен")
+		return apperr.New(apperr.CodeProviderUnavailable)
+	}
+
+	// Состояние входа одноразовое: cookie удаляется в любом случае
+	state, err := auth.ParseOIDCState(c.Cookies(oidcStateCookie))
+	c.ClearCookie(oidcStateCookie)
+	if err != nil {
+		return unauthorized("Невалидное состояние входа OIDC", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		return unauthorized("Параметр state не совпадает", nil)
+	}
+
+	token, err := client.OAuth2.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(state.Verifier))
+	if err != nil {
+		return unauthorized("Ошибка обмена кода авторизации", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return unauthorized("Провайдер не вернул ID-токен", nil)
+	}
+	idToken, err := client.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return unauthorized("Невалидный ID-токен", err)
+	}
+
+	claims, err := auth.ParseOIDCClaims(idToken)
+	if err != nil {
+		return unauthorized("Ошибка чтения claims ID-токена", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(state.Nonce)) != 1 {
+		return unauthorized("Параметр nonce не совпадает", nil)
+	}
+
+	role := auth.RoleForGroups(claims.Groups)
+	if role == "" {
+		log.WithField("groups", claims.Groups).Warn("Группы пользователя OIDC не сопоставлены ни одной роли")
+		return apperr.New(apperr.CodeNoRoleAssigned)
+	}
+
+	user, err := findOrCreateOIDCUser(ctx, idToken.Issuer+"|"+claims.Subject, claims, role, audit.FromRequest(c))
+	if err != nil {
+		log.WithError(err).Error("Ошибка сохранения пользователя OIDC")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if user.Disabled {
+		return unauthorized("Учетная запись отключена", nil)
+	}
+
+	principal := &auth.Principal{
+		UserID:   user.Id,
+		Username: user.Username,
+		Role:     user.Role,
+		MFA:      auth.MFAFromAMR(claims.AMR),
+	}
+
+	// Если провайдер не подтвердил второй фактор, а у пользователя включен TOTP,
+	// вход завершается вторым шагом (LoginMFA), как и при входе по паролю
+	if user.TOTPEnabled && !principal.MFA {
+		return respondWithMFAChallenge(c, principal)
+	}
+
+	// Токен выдается тем же путем, что и при входе по паролю
+	return respondWithToken(c, principal)
+}
+
+// findOrCreateOIDCUser находит пользователя по идентификатору у провайдера, затем по
+// подтвержденному адресу, иначе создает нового. У учетных записей, созданных при входе
+// через OIDC, роль берется из групп провайдера при каждом входе, ее изменение записывается
+// в журнал аудита по шаблону event. Роль локальных учетных записей, привязанных по адресу,
+// не меняется.
+func findOrCreateOIDCUser(ctx context.Context, subject string, claims *auth.OIDCClaims, role string, event models.AuditEvent) (*models.User, error) {
+	var user models.User
+	err := audit.Transaction(database.DB.WithContext(ctx), func(tx *gorm.DB) error {
+		// auditRoleChange записывает смену роли найденного пользователя
+		auditRoleChange := func() error {
+			if user.Role == role {
+				return nil
+			}
+			event.Actor = "oidc:" + user.Username
+			event.Action = models.AuditRoleChange
+			event.TargetType = models.AuditTargetUser
+			event.TargetId = user.Username
+			event.Before = audit.Snapshot(fiber.Map{"Role": user.Role})
+			event.After = audit.Snapshot(fiber.Map{"Role": role})
+			return audit.Record(tx, event)
+		}
+
+		err := tx.Where("oidc_subject = ?", subject).First(&user).Error
+		if err == nil {
+			updates := map[string]interface{}{"email_verified": user.EmailVerified || claims.EmailVerified}
+			if user.OIDCManaged {
+				if err := auditRoleChange(); err != nil {
+					return err
+				}
+				updates["role"] = role
+			}
+			return tx.Model(&user).Updates(updates).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email != "" && claims.EmailVerified {
+			err = tx.Where("LOWER(email) = LOWER(?)", claims.Email).First(&user).Error
+			if err == nil {
+				return tx.Model(&user).Updates(map[string]interface{}{
+					"oidc_subject":   subject,
+					"email_verified": true,
+				}).Error
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		user = models.User{
+			Username:      oidcUsername(tx, claims),
+			Email:         claims.Email,
+			Role:          role,
+			EmailVerified: claims.EmailVerified,
+			OIDCSubject:   &subject,
+			OIDCManaged:   true,
+		}
+		if user.Email == "" {
+			user.Email = claims.Subject + "@oidc.invalid"
+		}
+		return tx.Create(&user).Error
+	})
+	return &user, err
+}
+
+// maxUsernameLength длина колонки users.username в символах
+const maxUsernameLength = 64
+
+// oidcUsername выбирает свободное имя пользователя на основе claims провайдера
+func oidcUsername(tx *gorm.DB, claims *auth.OIDCClaims) string {
+	name := oidcBaseUsername(claims)
+
+	var count int64
+	tx.Model(&models.User{}).Where("LOWER(username) = LOWER(?)", name).Count(&count)
+	if count == 0 {
+		return name
+	}
+	return oidcUniqueUsername(name, claims.Subject)
+}
+
+// oidcBaseUsername имя из preferred_username, адреса или идентификатора у провайдера,
+// усеченное до длины колонки
+func oidcBaseUsername(claims *auth.OIDCClaims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	if name == "" {
+		name = claims.Subject
+	}
+	return truncateRunes(name, maxUsernameLength)
+}
+
+// oidcUniqueUsername добавляет к занятому имени суффикс из идентификатора у провайдера,
+// при необходимости укорачивая имя, чтобы результат поместился в колонку
+func oidcUniqueUsername(name, subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	suffix := "-" + hex.EncodeToString(sum[:3])
+	return truncateRunes(name, maxUsernameLength-len(suffix)) + suffix
+}
+
+// truncateRunes оставляет не больше n первых символов строки
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"test/apperr"
+	"test/auth"
+	"test/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	testClientID    = "news-app"
+	testRedirectURL = "http://news.test/api/oidc/callback"
+)
+
+// oidcAuthRequest запрос авторизации, к которому привязан выданный код
+type oidcAuthRequest struct {
+	Challenge string
+	Nonce     string
+}
+
+// mockOIDC OIDC-провайдер для тестов: discovery, authorize с PKCE (S256), token и JWKS
+type mockOIDC struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu           sync.Mutex
+	codes        map[string]oidcAuthRequest
+	tokenCalls   int
+	signKey      *rsa.PrivateKey // ключ подписи ID-токена, по умолчанию key
+	nonce        string          // подменяет nonce в ID-токене
+	pkceRejected int
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDC{key: key, codes: make(map[string]oidcAuthRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                m.srv.URL,
+			"authorization_endpoint":                m.srv.URL + "/authorize",
+			"token_endpoint":                        m.srv.URL + "/token",
+			"jwks_uri":                              m.srv.URL + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("GET /authorize", m.authorize)
+	mux.HandleFunc("POST /token", m.token)
+
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (m *mockOIDC) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != testClientID ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := "code-" + q.Get("state")[:8]
+	m.mu.Lock()
+	m.codes[code] = oidcAuthRequest{Challenge: q.Get("code_challenge"), Nonce: q.Get("nonce")}
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockOIDC) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenCalls++
+
+	req, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != req.Challenge {
+		m.pkceRejected++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := req.Nonce
+	if m.nonce != "" {
+		nonce = m.nonce
+	}
+	signKey := m.key
+	if m.signKey != nil {
+		signKey = m.signKey
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.srv.URL,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"nonce":          nonce,
+		"amr":            []string{"pwd"},
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = "test-key"
+	signed, err := idToken.SignedString(signKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "provider-access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// configure сбрасывает настройки провайдера перед подтестом
+func (m *mockOIDC) configure(fn func(m *mockOIDC)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signKey, m.nonce, m.tokenCalls, m.pkceRejected = nil, "", 0, 0
+	if fn != nil {
+		fn(m)
+	}
+}
+
+func (m *mockOIDC) stats() (tokenCalls, pkceRejected int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokenCalls, m.pkceRejected
+}
+
+// oidcLogin начинает вход: возвращает cookie состояния и параметры возврата от провайдера
+func oidcLogin(t *testing.T, app *fiber.App) (*http.Cookie, url.Values) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/oidc/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusFound {
+		t.Fatalf("вход: статус %d", resp.StatusCode)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("нет cookie состояния входа")
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authResp, err := noRedirect.Get(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authResp.Body.Close()
+	if authResp.StatusCode != http.StatusFound {
+		t.Fatalf("провайдер отклонил запрос авторизации: статус %d", authResp.StatusCode)
+	}
+	back, err := url.Parse(authResp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(back.String(), testRedirectURL) {
+		t.Fatalf("неожиданный адрес возврата %q", authResp.Header.Get("Location"))
+	}
+	return cookie, back.Query()
+}
+
+// oidcCallback вызывает обработчик возврата и возвращает код ошибки из ответа
+func oidcCallback(t *testing.T, app *fiber.App, cookie *http.Cookie, params url.Values) string {
+	t.Helper()
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/oidc/callback?"+params.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var problem apperr.Problem
+	if err := json.Unmarshal(body, &problem); err != nil {
+		t.Fatalf("ответ не problem+json: %s", body)
+	}
+	return problem.Code
+}
+
+func TestOIDCCallback(t *testing.T) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+
+	provider := newMockOIDC(t)
+	viper.Set("JWT_SECRET", "test-secret")
+	viper.Set("OIDC_ISSUER_URL", provider.srv.URL)
+	viper.Set("OIDC_CLIENT_ID", testClientID)
+	viper.Set("OIDC_CLIENT_SECRET", "client-secret")
+	viper.Set("OIDC_REDIRECT_URL", testRedirectURL)
+	// Без групп и роли по умолчанию вход останавливается сразу после проверки
+	// ID-токена, до обращения к БД: no_role_assigned означает, что проверки пройдены
+	viper.Set("OIDC_DEFAULT_ROLE", "")
+	t.Cleanup(func() {
+		for _, key := range []string{"JWT_SECRET", "OIDC_ISSUER_URL", "OIDC_CLIENT_ID", "OIDC_CLIENT_SECRET", "OIDC_REDIRECT_URL", "OIDC_DEFAULT_ROLE"} {
+			viper.Set(key, nil)
+		}
+	})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.Handler})
+	app.Get("/api/oidc/login", OIDCLogin)
+	app.Get("/api/oidc/callback", OIDCCallback)
+
+	t.Run("verified", func(t *testing.T) {
+		provider.configure(nil)
+		cookie, params := oidcLogin(t, app)
+		if code := oidcCallback(t, app, cookie, params); code != apperr.CodeNoRoleAssigned {
+			t.Fatalf("код %q, ожидался %q", code, apperr.CodeNoRoleAssigned)
+		}
+		if calls, rejected := provider.stats(); calls != 1 || rejected != 0 {
+			t.Fatalf("обращений к token: %d, отклонено PKCE: %d", calls, rejected)
+		}
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		provider.configure(nil)
+		cookie, params := oidcLogin(t, app)
+		params.Set("state", "forged")
+		if code := oidcCallback(t, app, cookie, params); code != apperr.CodeOIDCLoginFailed {
+			t.Fatalf("код %q, ожидался %q", code, apperr.CodeOIDCLoginFailed)
+		}
+		if calls, _ := provider.stats(); calls != 0 {
+			t.Fatal("код обменян несмотря на неверный state")
+		}
+	})
+
+	t.Run("missing state cookie", func(t *testing.T) {
+		provider.configure(nil)
+		_, params := oidcLogin(t, app)
+		if code := oidcCallback(t, app, nil, params); code != apperr.CodeOIDCLoginFailed {
+			t.Fatalf("код %q, ожидался %q", code, apperr.CodeOIDCLoginFailed)
+		}
+	})
+
+	t.Run("pkce verifier mismatch", func(t *testing.T) {
+		provider.configure(nil)
+		_, first := oidcLogin(t, app)
+		cookie, second := oidcLogin(t, app)
+		// Код первого входа с состоянием (и code_verifier) второго
+		second.Set("code", first.Get("code"))
+		if code := oidcCallback(t, app, cookie, second); code != apperr.CodeOIDCLoginFailed {
+			t.Fatalf("код %q, ожидался %q", code, apperr.CodeOIDCLoginFailed)
+		}
+		if _, rejected := provider.stats(); rejected != 1 {
+			t.Fatalf("провайдер не отклонил code_verifier: %d", rejected)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		provider.configure(func(m *mockOIDC) { m.nonce = "replayed" })
+		cookie, params := oidcLogin(t, app)
+		if code := oidcCallback(t, app, cookie, params); code != apperr.CodeOIDCLoginFailed {
+			t.Fatalf("код %q, ожидался %q", code, apperr.CodeOIDCLoginFailed)
+		}
+	})
+
+	t.Run("foreign signing key", func(t *testing.T) {
+		foreign, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider.configure(func(m *mockOIDC) { m.signKey = foreign })
+		cookie, params := oidcLogin(t, app)
+		if code := oidcCallback(t, app, cookie, params); code != apperr.CodeOIDCLoginFailed {
+			t.Fatalf("код %q, ожидался %q", code, apperr.CodeOIDCLoginFailed)
+		}
+	})
+}
+
+func TestOIDCUsernameLength(t *testing.T) {
+	long := strings.Repeat("я", 100)
+	for _, claims := range []*auth.OIDCClaims{
+		{PreferredUsername: long, Subject: "sub-1"},
+		{Email: long + "@example.com", Subject: "sub-2"},
+		{Subject: long},
+	} {
+		name := oidcBaseUsername(claims)
+		if n := utf8.RuneCountInString(name); n != maxUsernameLength {
+			t.Errorf("длина имени %d символов, ожидалось %d", n, maxUsernameLength)
+		}
+
+		unique := oidcUniqueUsername(name, claims.Subject)
+		if n := utf8.RuneCountInString(unique); n > maxUsernameLength {
+			t.Errorf("длина имени с суффиксом %d символов, больше %d", n, maxUsernameLength)
+		}
+		if unique == name || !strings.HasPrefix(unique, string([]rune(name)[:maxUsernameLength-7])) {
+			t.Errorf("имя с суффиксом %q не продолжает %q", unique, name)
+		}
+	}
+
+	if name := oidcBaseUsername(&auth.OIDCClaims{PreferredUsername: "alice", Subject: "sub"}); name != "alice" {
+		t.Errorf("короткое имя изменено: %q", name)
+	}
+}
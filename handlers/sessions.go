@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/i18n"
+	"test/logger"
+	"test/models"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// RefreshToken обменивает refresh-токен на новый токен доступа и новый refresh-токен
+func RefreshToken(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	session, refreshToken, err := auth.RefreshSession(c.UserContext(), req.RefreshToken, c.IP())
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		log.Warn("Повторно предъявлен замененный refresh-токен, сеанс отозван")
+		return apperr.New(apperr.CodeRefreshTokenInvalid)
+	}
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		log.Warn("Невалидный refresh-токен")
+		return apperr.New(apperr.CodeRefreshTokenInvalid)
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка обновления сеанса")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	principal := auth.Principal{
+		UserID:    session.UserId,
+		Username:  session.Username,
+		Role:      session.Role,
+		MFA:       session.MFA,
+		SessionID: session.Id,
+	}
+
+	// Роль и статус учетной записи берем актуальные: изменения вступают в силу при обновлении токена
+	if session.UserId != 0 {
+		var user models.User
+		err := database.DB.WithContext(c.UserContext()).First(&user, session.UserId).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.WithError(err).Error("Ошибка поиска пользователя")
+			return apperr.New(apperr.CodeInternal)
+		}
+		if err != nil || user.Disabled {
+			log.WithField("session_id", session.Id).Warn("Пользователь сеанса недоступен, сеанс отозван")
+			owner := auth.SessionOwner{UserID: session.UserId, Username: session.Username}
+			if _, err := auth.RevokeSession(c.UserContext(), database.DB, owner, session.Id); err != nil {
+				log.WithError(err).Error("Ошибка отзыва сеанса")
+			}
+			return apperr.New(apperr.CodeRefreshTokenInvalid)
+		}
+		principal.Role = user.Role
+	}
+
+	tokenString, err := auth.IssueAccessToken(principal)
+	if err != nil {
+		log.WithError(err).Error("Ошибка создания JWT-токена")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("session_id", session.Id).Info("Токен доступа обновлен")
+	return c.JSON(fiber.Map{
+		"Success":      true,
+		"Token":        tokenString,
+		"RefreshToken": refreshToken,
+	})
+}
+
+// ListSessions возвращает действующие сеансы текущего пользователя
+func ListSessions(c *fiber.Ctx) error {
+	principal := auth.PrincipalFrom(c)
+	return listSessions(c, auth.OwnerOf(principal), principal.SessionID)
+}
+
+// RevokeSession отзывает сеанс текущего пользователя
+func RevokeSession(c *fiber.Ctx) error {
+	return revokeSession(c, auth.OwnerOf(auth.PrincipalFrom(c)), false)
+}
+
+// RevokeOtherSessions отзывает все сеансы текущего пользователя, кроме текущего
+func RevokeOtherSessions(c *fiber.Ctx) error {
+	principal := auth.PrincipalFrom(c)
+	if principal.SessionID == 0 {
+		return apperr.New(apperr.CodeSessionRequired)
+	}
+	return revokeSessions(c, auth.OwnerOf(principal), principal.SessionID, false)
+}
+
+// ListUserSessions возвращает действующие сеансы пользователя :username (для администратора)
+func ListUserSessions(c *fiber.Ctx) error {
+	owner, err := sessionOwner(c, c.Params("username"))
+	if err != nil {
+		return err
+	}
+	return listSessions(c, owner, auth.PrincipalFrom(c).SessionID)
+}
+
+// RevokeUserSession отзывает сеанс пользователя :username (для администратора)
+func RevokeUserSession(c *fiber.Ctx) error {
+	owner, err := sessionOwner(c, c.Params("username"))
+	if err != nil {
+		return err
+	}
+	return revokeSession(c, owner, true)
+}
+
+// RevokeUserSessions отзывает все сеансы пользователя :username (для администратора)
+func RevokeUserSessions(c *fiber.Ctx) error {
+	owner, err := sessionOwner(c, c.Params("username"))
+	if err != nil {
+		return err
+	}
+	return revokeSessions(c, owner, 0, true)
+}
+
+// sessionOwner определяет владельца сеансов по имени: пользователя из БД по
+// идентификатору, иначе тестового пользователя из переменных окружения по имени.
+// Прочие имена — user_not_found.
+func sessionOwner(c *fiber.Ctx, username string) (auth.SessionOwner, error) {
+	var user models.User
+	err := database.DB.WithContext(c.UserContext()).Where("LOWER(username) = LOWER(?)", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if testUsername := viper.GetString("TEST_USERNAME"); testUsername != "" && strings.EqualFold(username, testUsername) {
+			return auth.SessionOwner{Username: testUsername}, nil
+		}
+		return auth.SessionOwner{}, apperr.New(apperr.CodeUserNotFound)
+	}
+	if err != nil {
+		logger.Ctx(c).WithError(err).Error("Ошибка поиска пользователя")
+		return auth.SessionOwner{}, apperr.New(apperr.CodeInternal)
+	}
+	return auth.SessionOwner{UserID: user.Id, Username: user.Username}, nil
+}
+
+func listSessions(c *fiber.Ctx, owner auth.SessionOwner, currentID uint) error {
+	sessions, err := auth.ActiveSessions(c.UserContext(), owner)
+	if err != nil {
+		logger.Ctx(c).WithError(err).Error("Ошибка получения сеансов")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == currentID
+	}
+	return c.JSON(fiber.Map{
+		"Success":  true,
+		"Sessions": sessions,
+	})
+}
+
+// revokeSession отзывает сеанс :id владельца. Отзыв администратором (audited)
+// записывается в журнал аудита.
+func revokeSession(c *fiber.Ctx, owner auth.SessionOwner, audited bool) error {
+	log := logger.Ctx(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return apperr.New(apperr.CodeInvalidID)
+	}
+
+	var revoked bool
+	err = audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		var err error
+		revoked, err = auth.RevokeSession(c.UserContext(), tx, owner, uint(id))
+		if err != nil || !revoked || !audited {
+			return err
+		}
+		return recordSessionAudit(c, tx, models.AuditSessionRevoke, owner, fiber.Map{"SessionId": id})
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка отзыва сеанса")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if !revoked {
+		return apperr.New(apperr.CodeSessionNotFound)
+	}
+
+	log.WithField("session_id", id).Info("Сеанс отозван")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.session_revoked"),
+	})
+}
+
+// revokeSessions отзывает все сеансы владельца, кроме exceptID. Отзыв администратором
+// (audited) записывается в журнал аудита.
+func revokeSessions(c *fiber.Ctx, owner auth.SessionOwner, exceptID uint, audited bool) error {
+	log := logger.Ctx(c)
+
+	var count int64
+	err := audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		var err error
+		count, err = auth.RevokeSessions(c.UserContext(), tx, owner, exceptID)
+		if err != nil || !audited {
+			return err
+		}
+		return recordSessionAudit(c, tx, models.AuditSessionRevokeAll, owner, fiber.Map{"Revoked": count})
+	})
+	if err != nil {
+		log.WithError(err).Error("Ошибка отзыва сеансов")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("revoked", count).Info("Сеансы отозваны")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Revoked": count,
+	})
+}
+
+// recordSessionAudit записывает отзыв сеансов пользователя администратором в журнал аудита
+func recordSessionAudit(c *fiber.Ctx, tx *gorm.DB, action string, owner auth.SessionOwner, after fiber.Map) error {
+	event := audit.FromRequest(c)
+	event.Action = action
+	event.TargetType = models.AuditTargetUser
+	event.TargetId = owner.Username
+	event.After = audit.Snapshot(after)
+	return audit.Record(tx, event)
+}
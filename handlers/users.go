@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/i18n"
+	"test/logger"
+	"test/models"
+	"test/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateUser создает учетную запись и отправляет письмо для подтверждения адреса
+func CreateUser(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Username string `json:"username" validate:"required,max=64"`
+		Email    string `json:"email" validate:"required,email,max=255"`
+		Password string `json:"password" validate:"max=72"`
+		Role     string `json:"role" validate:"omitempty,oneof=admin editor viewer"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if errors.Is(err, auth.ErrPasswordTooShort) {
+		return apperr.New(apperr.CodePasswordTooShort).WithDetail("detail.password_too_short", auth.MinPasswordLength)
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка хеширования пароля")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	user := models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hash,
+		Role:         req.Role,
+	}
+
+	err = audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		event := audit.FromRequest(c)
+		event.Action = models.AuditUserCreate
+		event.TargetType = models.AuditTargetUser
+		event.TargetId = user.Username
+		event.After = audit.Snapshot(fiber.Map{"Id": user.Id, "Email": user.Email, "Role": user.Role})
+		if err := audit.Record(tx, event); err != nil {
+			return err
+		}
+
+		return enqueueVerificationEmail(tx, &user, i18n.Lang(c))
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return apperr.New(apperr.CodeUserExists)
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка создания пользователя")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithField("new_user_id", user.Id).Info("Пользователь создан")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"Message": i18n.T(c, "message.user_created"),
+		"User":    user,
+	})
+}
+
+// SetUserRole меняет роль пользователя. Новая роль попадает в токен доступа
+// при следующем обновлении по refresh-токену.
+func SetUserRole(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	var req struct {
+		Role string `json:"role" validate:"required,oneof=admin editor viewer"`
+	}
+	if err := validation.Body(c, &req); err != nil {
+		return err
+	}
+
+	var user models.User
+	err := audit.Transaction(database.DB.WithContext(c.UserContext()), func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("LOWER(username) = LOWER(?)", c.Params("username")).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+		if user.Role == req.Role {
+			return nil
+		}
+
+		event := audit.FromRequest(c)
+		event.Action = models.AuditRoleChange
+		event.TargetType = models.AuditTargetUser
+		event.TargetId = user.Username
+		event.Before = audit.Snapshot(fiber.Map{"Role": user.Role})
+		event.After = audit.Snapshot(fiber.Map{"Role": req.Role})
+
+		if err := tx.Model(&user).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, event)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.New(apperr.CodeUserNotFound)
+	}
+	if err != nil {
+		log.WithError(err).Error("Ошибка смены роли")
+		return apperr.New(apperr.CodeInternal)
+	}
+
+	log.WithFields(logrus.Fields{"target_user": user.Username, "role": user.Role}).Info("Роль пользователя изменена")
+	return c.JSON(fiber.Map{
+		"Success": true,
+		"User":    user,
+	})
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check проверяет доступность одной зависимости (БД, кеш, очередь и т.д.)
+type Check func(ctx context.Context) error
+
+// Result результат выполнения одной проверки
+type Result struct {
+	Name       string  `json:"Name"`
+	Status     string  `json:"Status"`
+	DurationMs float64 `json:"DurationMs"`
+	Error      string  `json:"Error,omitempty"`
+}
+
+// Report сводный отчет о готовности приложения
+type Report struct {
+	Status string   `json:"Status"`
+	Checks []Result `json:"Checks"`
+}
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+var (
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+)
+
+// Register добавляет проверку готовности. Кеши и очереди регистрируют
+// свои проверки здесь же при инициализации.
+func Register(name string, check Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks = append(checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown переводит приложение в режим остановки: readiness начинает отвечать отказом
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// ShuttingDown сообщает, идет ли остановка приложения
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// Run параллельно выполняет все зарегистрированные проверки
+func Run(ctx context.Context) Report {
+	mu.RLock()
+	registered := make([]namedCheck, len(checks))
+	copy(registered, checks)
+	mu.RUnlock()
+
+	results := make([]Result, len(registered))
+	var wg sync.WaitGroup
+	for i, nc := range registered {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := nc.check(ctx)
+			results[i] = Result{
+				Name:       nc.name,
+				Status:     StatusOK,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = StatusFail
+				results[i].Error = err.Error()
+			}
+		}(i, nc)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+			break
+		}
+	}
+	if ShuttingDown() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+// Каталоги сообщений: locales/<язык>.json, плоский объект "ключ": "сообщение".
+// Чтобы добавить язык, достаточно добавить файл каталога.
+//
+//go:embed locales/*.json
+var files embed.FS
+
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	result := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("каталог сообщений %s: %v", entry.Name(), err))
+		}
+		result[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return result
+}
+
+// Languages возвращает языки, для которых есть каталоги
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// DefaultLanguage язык по умолчанию (DEFAULT_LANGUAGE, по умолчанию ru)
+func DefaultLanguage() string {
+	if lang := viper.GetString("DEFAULT_LANGUAGE"); catalogs[lang] != nil {
+		return lang
+	}
+	return "ru"
+}
+
+// Negotiate выбирает язык по заголовку Accept-Language с учетом весов q.
+// Если ни один язык не подходит, возвращается язык по умолчанию.
+func Negotiate(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		// Сравниваем по основному подтегу: en-US -> en
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if lang == "*" {
+			lang = DefaultLanguage()
+		}
+		if catalogs[lang] != nil && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	if best == "" {
+		return DefaultLanguage()
+	}
+	return best
+}
+
+// Lang язык текущего запроса
+func Lang(c *fiber.Ctx) string {
+	if lang, ok := c.Locals("lang").(string); ok {
+		return lang
+	}
+	return Negotiate(c.Get(fiber.HeaderAcceptLanguage))
+}
+
+// Translate возвращает сообщение по ключу на указанном языке. Если перевода нет,
+// используется язык по умолчанию, затем сам ключ.
+func Translate(lang, key string, args ...interface{}) string {
+	message, ok := catalogs[lang][key]
+	if !ok {
+		message, ok = catalogs[DefaultLanguage()][key]
+	}
+	if !ok {
+		message = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Has сообщает, есть ли ключ в каталоге языка по умолчанию
+func Has(key string) bool {
+	_, ok := catalogs[DefaultLanguage()][key]
+	return ok
+}
+
+// T переводит сообщение на язык текущего запроса
+func T(c *fiber.Ctx, key string, args ...interface{}) string {
+	return Translate(Lang(c), key, args...)
+}
@@ -0,0 +1,248 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"test/database"
+	"test/logger"
+	"test/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Policy параметры защиты от перебора паролей
+type Policy struct {
+	MaxFailures     int           // Количество неудачных попыток до блокировки
+	LockoutDuration time.Duration // Длительность блокировки
+	FailureWindow   time.Duration // Через сколько после последней ошибки счетчик сбрасывается
+	BaseDelay       time.Duration // Начальная задержка между попытками, удваивается с каждой ошибкой
+	MaxDelay        time.Duration // Максимальная задержка между попытками
+}
+
+// UserPolicy политика для учетной записи: LOGIN_MAX_FAILURES, LOGIN_LOCKOUT_DURATION,
+// LOGIN_FAILURE_WINDOW, LOGIN_DELAY_BASE и LOGIN_DELAY_MAX
+func UserPolicy() Policy {
+	return Policy{
+		MaxFailures:     intOr("LOGIN_MAX_FAILURES", 5),
+		LockoutDuration: durationOr("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		FailureWindow:   durationOr("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		BaseDelay:       durationOr("LOGIN_DELAY_BASE", time.Second),
+		MaxDelay:        durationOr("LOGIN_DELAY_MAX", 30*time.Second),
+	}
+}
+
+// IPPolicy политика для IP-адреса: порог LOGIN_IP_MAX_FAILURES, остальное как у UserPolicy
+func IPPolicy() Policy {
+	p := UserPolicy()
+	p.MaxFailures = intOr("LOGIN_IP_MAX_FAILURES", 20)
+	return p
+}
+
+// ChallengePolicy политика для токена MFA-запроса: после MFA_MAX_FAILURES (5) неверных
+// кодов токен перестает действовать. Задержки не нужны — их дает политика учетной записи.
+func ChallengePolicy() Policy {
+	return Policy{
+		MaxFailures:     intOr("MFA_MAX_FAILURES", 5),
+		LockoutDuration: durationOr("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		FailureWindow:   durationOr("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	}
+}
+
+// UserKey ключ учетной записи. Ключ не зависит от существования пользователя,
+// поэтому блокировка не раскрывает, зарегистрировано ли имя.
+func UserKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// IPKey ключ IP-адреса
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ChallengeKey ключ токена MFA-запроса по его идентификатору
+func ChallengeKey(jti string) string {
+	return "mfa:" + jti
+}
+
+// Failures возвращает число учтенных неудачных попыток по ключу
+func Failures(ctx context.Context, key string) (int, error) {
+	var failures []int
+	err := database.DB.WithContext(ctx).Model(&models.LoginFailure{}).Where("key = ?", key).Pluck("failures", &failures).Error
+	if err != nil || len(failures) == 0 {
+		return 0, err
+	}
+	return failures[0], nil
+}
+
+// Check возвращает, сколько нужно подождать до следующей попытки по любому из ключей
+func Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	var failures []models.LoginFailure
+	if err := database.DB.WithContext(ctx).Where("key IN ?", keys).Find(&failures).Error; err != nil {
+		return 0, fmt.Errorf("ошибка чтения неудачных попыток входа: %v", err)
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, f := range failures {
+		until := f.NextAttemptAt
+		if f.LockedUntil != nil && f.LockedUntil.After(until) {
+			until = *f.LockedUntil
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// RecordFailure учитывает неудачную попытку: увеличивает задержку до следующей попытки
+// и блокирует ключ при достижении порога
+func RecordFailure(ctx context.Context, key, ip string, policy Policy) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var f models.LoginFailure
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&f).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			f = models.LoginFailure{Key: key}
+		}
+
+		// Давние ошибки и истекшая блокировка не учитываются
+		lockExpired := f.LockedUntil != nil && !f.LockedUntil.After(now)
+		if lockExpired || now.Sub(f.LastFailureAt) > policy.FailureWindow {
+			f.Failures = 0
+			f.LockedUntil = nil
+		}
+
+		f.Failures++
+		f.LastFailureAt = now
+		f.NextAttemptAt = now.Add(policy.delay(f.Failures))
+
+		newlyLocked := false
+		if policy.MaxFailures > 0 && f.Failures >= policy.MaxFailures && f.LockedUntil == nil {
+			lockedUntil := now.Add(policy.LockoutDuration)
+			f.LockedUntil = &lockedUntil
+			newlyLocked = true
+		}
+
+		if err := tx.Save(&f).Error; err != nil {
+			return err
+		}
+
+		// Для учетной записи запоминаем адреса, с которых шли попытки
+		if strings.HasPrefix(key, "user:") && ip != "" {
+			source := models.LoginFailureSource{Key: key, IP: ip}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&source).Error; err != nil {
+				return err
+			}
+		}
+
+		if newlyLocked {
+			logger.FromContext(ctx).WithFields(logrus.Fields{
+				"key":          key,
+				"failures":     f.Failures,
+				"locked_until": f.LockedUntil,
+			}).Warn("Вход временно заблокирован после неудачных попыток")
+			return tx.Create(&models.SecurityEvent{
+				Type:      models.SecurityEventLockout,
+				Subject:   key,
+				IP:        ip,
+				Details:   fmt.Sprintf("failures=%d locked_until=%s", f.Failures, f.LockedUntil.Format(time.RFC3339)),
+				CreatedAt: now,
+			}).Error
+		}
+		return nil
+	})
+}
+
+// RecordSuccess сбрасывает счетчик неудачных попыток после успешного входа
+func RecordSuccess(ctx context.Context, key string) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("key = ?", key).Delete(&models.LoginFailure{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("key = ?", key).Delete(&models.LoginFailureSource{}).Error
+	})
+}
+
+// Unlock снимает блокировку учетной записи и записывает событие разблокировки.
+// С clearIPs снимаются и блокировки IP-адресов, с которых шли неудачные попытки
+// входа в нее; по умолчанию они остаются, чтобы разблокировка жертвы не сбрасывала
+// счетчики подбирающего пароль.
+func Unlock(ctx context.Context, username string, clearIPs bool, actor, ip string) error {
+	key := UserKey(username)
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("key = ?", key).Delete(&models.LoginFailure{}).Error; err != nil {
+			return err
+		}
+
+		event := models.SecurityEvent{
+			Type:      models.SecurityEventUnlock,
+			Subject:   key,
+			Actor:     actor,
+			IP:        ip,
+			CreatedAt: time.Now(),
+		}
+
+		if clearIPs {
+			var sources []string
+			if err := tx.Model(&models.LoginFailureSource{}).Where("key = ?", key).Pluck("ip", &sources).Error; err != nil {
+				return err
+			}
+			keys := make([]string, len(sources))
+			for i, source := range sources {
+				keys[i] = IPKey(source)
+			}
+			if len(keys) > 0 {
+				if err := tx.Where("key IN ?", keys).Delete(&models.LoginFailure{}).Error; err != nil {
+					return err
+				}
+				event.Details = "ips=" + strings.Join(sources, ",")
+			}
+			if err := tx.Where("key = ?", key).Delete(&models.LoginFailureSource{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&event).Error
+	})
+}
+
+// delay задержка после n-й неудачной попытки: BaseDelay * 2^(n-1), не более MaxDelay
+func (p Policy) delay(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func intOr(key string, def int) int {
+	if viper.IsSet(key) {
+		return viper.GetInt(key)
+	}
+	return def
+}
+
+func durationOr(key string, def time.Duration) time.Duration {
+	if viper.IsSet(key) {
+		return viper.GetDuration(key)
+	}
+	return def
+}
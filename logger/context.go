@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type entryKey struct{}
+
+// NewContext сохраняет логгер запроса в контексте
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// FromContext возвращает логгер запроса из контекста или общий логгер, если его нет
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey{}).(*logrus.Entry); ok {
+		return entry.WithContext(ctx)
+	}
+	return Logger.WithContext(ctx)
+}
+
+// Ctx возвращает логгер текущего запроса с полями request_id, user_id, method и route
+func Ctx(c *fiber.Ctx) *logrus.Entry {
+	return FromContext(c.UserContext()).WithField("route", c.Route().Path)
+}
+
+// AddFields добавляет поля к логгеру запроса для всех последующих записей
+func AddFields(c *fiber.Ctx, fields logrus.Fields) {
+	ctx := c.UserContext()
+	c.SetUserContext(NewContext(ctx, FromContext(ctx).WithFields(fields)))
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// В режиме prefork запрос на смену уровня попадает в один дочерний процесс.
+// Новый уровень сохраняется в общий файл, который остальные процессы периодически перечитывают.
+
+func levelFile() string {
+	path := viper.GetString("LOG_LEVEL_FILE")
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "news-app-log-level")
+	}
+	return path
+}
+
+// SetLevel меняет уровень логгирования во всех процессах приложения
+func SetLevel(level logrus.Level) error {
+	Logger.SetLevel(level)
+	if !fiber.IsChild() {
+		return nil
+	}
+	return os.WriteFile(levelFile(), []byte(level.String()), 0o644)
+}
+
+// watchLevel отслеживает изменения уровня, сделанные другими процессами
+func watchLevel() {
+	if !fiber.IsChild() {
+		// Мастер-процесс удаляет уровень, оставшийся от предыдущего запуска
+		_ = os.Remove(levelFile())
+		return
+	}
+
+	go func() {
+		for range time.NewTicker(2 * time.Second).C {
+			data, err := os.ReadFile(levelFile())
+			if err != nil {
+				continue
+			}
+			level, err := logrus.ParseLevel(strings.TrimSpace(string(data)))
+			if err != nil || level == Logger.GetLevel() {
+				continue
+			}
+			Logger.SetLevel(level)
+		}
+	}()
+}
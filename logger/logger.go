@@ -1,23 +1,152 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Logger экземпляр Logrus
 var Logger *logrus.Logger
 
-func InitLogger() {
+// InitLogger настраивает логгер по конфигурации:
+// LOG_LEVEL, LOG_FORMAT (json/text), LOG_OUTPUTS (stderr, stdout, file) и LOG_ERROR_OUTPUT
+func InitLogger() error {
 	Logger = logrus.New()
 
-	// Устанавливаем формат вывода (JSON)
-	Logger.SetFormatter(&logrus.JSONFormatter{})
+	// Устанавливаем формат вывода
+	formatter, err := newFormatter(viper.GetString("LOG_FORMAT"))
+	if err != nil {
+		return err
+	}
+	Logger.SetFormatter(formatter)
 
 	// Устанавливаем уровень логгирования
-	Logger.SetLevel(logrus.DebugLevel)
+	level := logrus.DebugLevel
+	if name := viper.GetString("LOG_LEVEL"); name != "" {
+		if level, err = logrus.ParseLevel(name); err != nil {
+			return fmt.Errorf("неверный уровень логгирования %q: %v", name, err)
+		}
+	}
+	Logger.SetLevel(level)
 
-	// Устанавливаем вывод в стандартный поток ошибок
-	Logger.SetOutput(os.Stderr)
+	// Устанавливаем выводы (по умолчанию стандартный поток ошибок)
+	outputs := []string{"stderr"}
+	if raw := viper.GetString("LOG_OUTPUTS"); raw != "" {
+		outputs = strings.Split(raw, ",")
+	}
+	writers := make([]io.Writer, 0, len(outputs))
+	for _, name := range outputs {
+		w, err := newOutput(strings.TrimSpace(name), viper.GetString("LOG_FILE"))
+		if err != nil {
+			return err
+		}
+		writers = append(writers, w)
+	}
+	Logger.SetOutput(io.MultiWriter(writers...))
+
+	// Добавляем идентификаторы трассировки
+	Logger.AddHook(traceHook{})
+
+	// Ошибки дополнительно пишем в отдельный вывод
+	if name := viper.GetString("LOG_ERROR_OUTPUT"); name != "" {
+		w, err := newOutput(name, viper.GetString("LOG_ERROR_FILE"))
+		if err != nil {
+			return err
+		}
+		Logger.AddHook(&errorSinkHook{writer: w, formatter: formatter})
+	}
+
+	// Уровень может меняться во время работы через административный эндпоинт
+	watchLevel()
+
+	return nil
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "", "json":
+		return &logrus.JSONFormatter{}, nil
+	case "text":
+		return &logrus.TextFormatter{FullTimestamp: true}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов: %s", format)
+	}
+}
+
+// newOutput создает вывод: stderr, stdout, file (путь из file) или путь к файлу
+func newOutput(name, file string) (io.Writer, error) {
+	switch name {
+	case "stderr":
+		return os.Stderr, nil
+	case "stdout":
+		return os.Stdout, nil
+	case "file":
+		if file == "" {
+			return nil, fmt.Errorf("для вывода логов в файл не задан путь")
+		}
+		return newRotatingFile(file), nil
+	case "":
+		return nil, fmt.Errorf("пустое имя вывода логов")
+	default:
+		return newRotatingFile(name), nil
+	}
+}
+
+// newRotatingFile создает файл с ротацией по размеру (LOG_FILE_MAX_SIZE_MB), по времени
+// (LOG_FILE_ROTATE_INTERVAL) и хранением LOG_FILE_MAX_BACKUPS файлов не дольше LOG_FILE_MAX_AGE_DAYS.
+// Подстановка {pid} в пути позволяет процессам prefork вести и ротировать собственные файлы.
+func newRotatingFile(path string) io.Writer {
+	path = strings.ReplaceAll(path, "{pid}", strconv.Itoa(os.Getpid()))
+
+	maxSize := viper.GetInt("LOG_FILE_MAX_SIZE_MB")
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+
+	file := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxBackups: viper.GetInt("LOG_FILE_MAX_BACKUPS"),
+		MaxAge:     viper.GetInt("LOG_FILE_MAX_AGE_DAYS"),
+		Compress:   viper.GetBool("LOG_FILE_COMPRESS"),
+	}
+
+	if interval := viper.GetDuration("LOG_FILE_ROTATE_INTERVAL"); interval > 0 {
+		go func() {
+			for range time.NewTicker(interval).C {
+				if err := file.Rotate(); err != nil {
+					fmt.Fprintf(os.Stderr, "ошибка ротации файла логов %s: %v\n", path, err)
+				}
+			}
+		}()
+	}
+
+	return file
+}
+
+// errorSinkHook дублирует записи уровня error и выше в отдельный вывод
+type errorSinkHook struct {
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+func (h *errorSinkHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel}
+}
+
+func (h *errorSinkHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(line)
+	return err
 }
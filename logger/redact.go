@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Redacted значение, которым заменяются чувствительные данные
+const Redacted = "[REDACTED]"
+
+// Redactor скрывает значения чувствительных заголовков, полей тела и параметров запроса
+type Redactor struct {
+	headers map[string]struct{}
+	fields  map[string]struct{}
+}
+
+// NewRedactor создает Redactor. Имена заголовков и полей сравниваются без учета регистра.
+func NewRedactor(headers, fields []string) *Redactor {
+	r := &Redactor{
+		headers: make(map[string]struct{}, len(headers)),
+		fields:  make(map[string]struct{}, len(fields)),
+	}
+	for _, h := range headers {
+		r.headers[strings.ToLower(h)] = struct{}{}
+	}
+	for _, f := range fields {
+		r.fields[strings.ToLower(f)] = struct{}{}
+	}
+	return r
+}
+
+// Header возвращает значение заголовка или Redacted, если заголовок чувствительный
+func (r *Redactor) Header(name, value string) string {
+	if _, ok := r.headers[strings.ToLower(name)]; ok {
+		return Redacted
+	}
+	return value
+}
+
+// URL возвращает адрес запроса со скрытыми значениями чувствительных параметров
+// запроса (например, code и state при возврате от OIDC-провайдера). Порядок
+// и запись остальных параметров не меняются.
+func (r *Redactor) URL(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		if _, ok := r.fields[strings.ToLower(key)]; ok {
+			params[i] = key + "=" + Redacted
+		}
+	}
+	return path + "?" + strings.Join(params, "&")
+}
+
+// Body возвращает JSON-тело со скрытыми чувствительными полями на любом уровне вложенности.
+// Тело, не являющееся JSON, в лог не попадает — вместо него указывается его размер.
+func (r *Redactor) Body(body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+	return r.redactValue(value)
+}
+
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, ok := r.fields[strings.ToLower(key)]; ok {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = r.redactValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
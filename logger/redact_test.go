@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactorURL(t *testing.T) {
+	r := NewRedactor(nil, []string{"code", "state"})
+	for _, tc := range []struct{ in, want string }{
+		{"/api/news/1", "/api/news/1"},
+		{"/api/oidc/callback?code=abc&state=xyz", "/api/oidc/callback?code=[REDACTED]&state=[REDACTED]"},
+		{"/api/list?page=2&Code=1&limit=10", "/api/list?page=2&Code=[REDACTED]&limit=10"},
+		{"/api/oidc/callback?st%61te=xyz", "/api/oidc/callback?state=[REDACTED]"},
+		{"/api/list?", "/api/list?"},
+	} {
+		if got := r.URL(tc.in); got != tc.want {
+			t.Errorf("URL(%q) = %q, ожидалось %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRedactorBody(t *testing.T) {
+	r := NewRedactor(nil, []string{"code", "recovery_code"})
+	got := r.Body([]byte(`{"challenge":"c","code":"123456","nested":[{"Recovery_Code":"r"}]}`))
+	want := map[string]interface{}{
+		"challenge": "c",
+		"code":      Redacted,
+		"nested":    []interface{}{map[string]interface{}{"Recovery_Code": Redacted}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Body: %v, ожидалось %v", got, want)
+	}
+	if got := r.Body([]byte("не JSON")); got != "<9 bytes>" {
+		t.Errorf("тело не JSON: %v", got)
+	}
+}
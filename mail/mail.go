@@ -0,0 +1,149 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Message письмо
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender отправляет письма
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPSender отправляет письма через SMTP-сервер
+type SMTPSender struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPSenderFromConfig создает SMTPSender по SMTP_HOST, SMTP_PORT, SMTP_USERNAME,
+// SMTP_PASSWORD и SMTP_FROM. Возвращает nil, если SMTP_HOST не задан.
+func NewSMTPSenderFromConfig() *SMTPSender {
+	host := viper.GetString("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port := viper.GetString("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	from := viper.GetString("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	return &SMTPSender{
+		Addr:     net.JoinHostPort(host, port),
+		Username: viper.GetString("SMTP_USERNAME"),
+		Password: viper.GetString("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+// Send отправляет письмо в рамках ctx: соединение получает срок ctx и закрывается
+// при его отмене, поэтому медленный сервер не оставляет висящих соединений.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) (err error) {
+	host, _, _ := net.SplitHostPort(s.Addr)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	// Ошибки закрытого или просроченного соединения сообщают причину из ctx. Срок
+	// соединения может истечь чуть раньше, чем ctx отметит его истечение.
+	defer func() {
+		var netErr net.Error
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case errors.As(err, &netErr) && netErr.Timeout():
+			err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+		}
+	}()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	// Те же шаги, что в smtp.SendMail: STARTTLS, если сервер его предлагает, затем AUTH
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.format(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// format формирует письмо в формате RFC 5322
+func (s *SMTPSender) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: =?UTF-8?B?%s?=\r\n", encodeBase64(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func encodeBase64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
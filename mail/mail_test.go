@@ -0,0 +1,245 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpSession то, что получил тестовый SMTP-сервер за одно соединение
+type smtpSession struct {
+	Auth string
+	From string
+	To   []string
+	Data string
+}
+
+// smtpStub минимальный SMTP-сервер для тестов. rejectRcpt отклоняет получателей,
+// silent принимает соединение, но не отвечает; closed получает сигнал, когда клиент
+// закрыл такое соединение.
+type smtpStub struct {
+	ln         net.Listener
+	rejectRcpt bool
+	silent     bool
+	closed     chan struct{}
+
+	mu       sync.Mutex
+	sessions []smtpSession
+}
+
+func newSMTPStub(t *testing.T, configure ...func(*smtpStub)) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{ln: ln, closed: make(chan struct{}, 1)}
+	for _, fn := range configure {
+		fn(s)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	if s.silent {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Read(make([]byte, 1)); errors.Is(err, io.EOF) {
+			s.closed <- struct{}{}
+		}
+		return
+	}
+
+	tp := textproto.NewConn(conn)
+	var session smtpSession
+	tp.PrintfLine("220 localhost ESMTP stub")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+			session.Auth = string(decoded)
+			tp.PrintfLine("235 Authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			session.From = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			tp.PrintfLine("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			if s.rejectRcpt {
+				tp.PrintfLine("550 No such user")
+				continue
+			}
+			session.To = append(session.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			session.Data = string(data)
+			s.mu.Lock()
+			s.sessions = append(s.sessions, session)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *smtpStub) received() []smtpSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpSession(nil), s.sessions...)
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	stub := newSMTPStub(t)
+	sender := &SMTPSender{Addr: stub.ln.Addr().String(), From: "news@example.com"}
+
+	err := sender.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Сброс пароля",
+		Body:    "Строка 1\nСтрока 2",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sessions := stub.received()
+	if len(sessions) != 1 {
+		t.Fatalf("получено писем: %d, ожидалось 1", len(sessions))
+	}
+	got := sessions[0]
+	if got.Auth != "" {
+		t.Errorf("аутентификация без SMTP_USERNAME: %q", got.Auth)
+	}
+	if got.From != "news@example.com" || len(got.To) != 1 || got.To[0] != "alice@example.com" {
+		t.Errorf("конверт: from=%q to=%v", got.From, got.To)
+	}
+
+	subject := "Subject: =?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte("Сброс пароля")) + "?="
+	for _, want := range []string{"From: news@example.com", "To: alice@example.com", subject, "Content-Type: text/plain; charset=UTF-8"} {
+		if !strings.Contains(got.Data, want) {
+			t.Errorf("в письме нет %q:\n%s", want, got.Data)
+		}
+	}
+	// ReadDotBytes заменяет CRLF на LF, поэтому проверяем, что строки тела разделены
+	if !strings.HasSuffix(got.Data, "\n\nСтрока 1\nСтрока 2\n") {
+		t.Errorf("тело письма:\n%q", got.Data)
+	}
+}
+
+func TestSMTPSenderAuth(t *testing.T) {
+	stub := newSMTPStub(t)
+	// PlainAuth без TLS разрешен только для localhost
+	_, port, _ := net.SplitHostPort(stub.ln.Addr().String())
+	sender := &SMTPSender{
+		Addr:     net.JoinHostPort("localhost", port),
+		Username: "mailer",
+		Password: "secret",
+		From:     "news@example.com",
+	}
+
+	if err := sender.Send(context.Background(), Message{To: "bob@example.com", Subject: "Тест", Body: "Тело"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sessions := stub.received()
+	if len(sessions) != 1 || sessions[0].Auth != "\x00mailer\x00secret" {
+		t.Fatalf("AUTH PLAIN: %+v", sessions)
+	}
+}
+
+func TestSMTPSenderRejectedRecipient(t *testing.T) {
+	stub := newSMTPStub(t, func(s *smtpStub) { s.rejectRcpt = true })
+	sender := &SMTPSender{Addr: stub.ln.Addr().String(), From: "news@example.com"}
+
+	err := sender.Send(context.Background(), Message{To: "nobody@example.com", Subject: "Тест", Body: "Тело"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("ожидалась ошибка 550, получено %v", err)
+	}
+	if len(stub.received()) != 0 {
+		t.Fatal("письмо не должно быть принято")
+	}
+}
+
+func TestSMTPSenderTimeout(t *testing.T) {
+	stub := newSMTPStub(t, func(s *smtpStub) { s.silent = true })
+	sender := &SMTPSender{Addr: stub.ln.Addr().String(), From: "news@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := sender.Send(ctx, Message{To: "alice@example.com", Subject: "Тест", Body: "Тело"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидался context.DeadlineExceeded, получено %v", err)
+	}
+
+	// Соединение закрывается вместе с отменой, а не остается висеть до ответа сервера
+	select {
+	case <-stub.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("соединение не закрыто после истечения срока")
+	}
+}
+
+func TestSMTPSenderCancel(t *testing.T) {
+	stub := newSMTPStub(t, func(s *smtpStub) { s.silent = true })
+	sender := &SMTPSender{Addr: stub.ln.Addr().String(), From: "news@example.com"}
+
+	// Отмена без срока: соединение закрывается по отмене контекста
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err := sender.Send(ctx, Message{To: "alice@example.com", Subject: "Тест", Body: "Тело"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ожидался context.Canceled, получено %v", err)
+	}
+	select {
+	case <-stub.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("соединение не закрыто после отмены")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		8:  maxRetryDelay,
+		50: maxRetryDelay,
+	}
+	for attempts, want := range cases {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, ожидалось %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"context"
+	"time"
+
+	"test/logger"
+	"test/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxRetryDelay максимальная пауза между попытками отправки письма
+	maxRetryDelay = time.Hour
+	// sendTimeout ограничение времени отправки одного письма
+	sendTimeout = 30 * time.Second
+)
+
+// Enqueue сохраняет письмо в очередь. Вызывается в транзакции вместе с изменениями,
+// ради которых письмо отправляется.
+func Enqueue(tx *gorm.DB, msg Message) error {
+	return tx.Create(&models.OutboxEmail{
+		To:            msg.To,
+		Subject:       msg.Subject,
+		Body:          msg.Body,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// StartOutboxWorker периодически (MAIL_OUTBOX_INTERVAL, по умолчанию 10s) отправляет
+// письма из очереди. Неудачные отправки повторяются с растущей паузой, письма не удаляются.
+func StartOutboxWorker(db *gorm.DB, sender Sender) {
+	interval := viper.GetDuration("MAIL_OUTBOX_INTERVAL")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	go func() {
+		for range time.NewTicker(interval).C {
+			if err := processOutbox(context.Background(), db, sender, 20); err != nil {
+				logger.Logger.WithError(err).Error("Ошибка обработки очереди писем")
+			}
+		}
+	}()
+}
+
+// processOutbox отправляет пачку готовых к отправке писем. Письма сначала забираются
+// короткой транзакцией, а отправляются уже после ее фиксации, чтобы обращения к
+// SMTP-серверу не удерживали блокировки строк.
+func processOutbox(ctx context.Context, db *gorm.DB, sender Sender, batch int) error {
+	emails, err := claimOutbox(ctx, db, batch)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := sender.Send(sendCtx, Message{To: email.To, Subject: email.Subject, Body: email.Body})
+		cancel()
+
+		updates := map[string]interface{}{"attempts": email.Attempts + 1}
+		if err != nil {
+			logger.Logger.WithError(err).WithFields(logrus.Fields{
+				"email_id": email.Id,
+				"attempts": email.Attempts + 1,
+			}).Warn("Ошибка отправки письма, будет повторная попытка")
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = time.Now().Add(retryDelay(email.Attempts + 1))
+		} else {
+			updates["sent_at"] = time.Now()
+			updates["last_error"] = ""
+		}
+
+		// Если результат не сохранится, письмо будет отправлено повторно после истечения аренды
+		if err := db.WithContext(ctx).Model(&models.OutboxEmail{}).Where("id = ?", email.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimOutbox забирает пачку писем в аренду: next_attempt_at переносится на время,
+// достаточное для отправки всей пачки, и другие процессы эти письма не берут.
+// Если процесс завершится, не успев отправить письма, после аренды их заберет другой.
+// SKIP LOCKED позволяет нескольким процессам забирать письма одновременно.
+func claimOutbox(ctx context.Context, db *gorm.DB, batch int) ([]models.OutboxEmail, error) {
+	var emails []models.OutboxEmail
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("next_attempt_at").
+			Limit(batch).
+			Find(&emails).Error
+		if err != nil || len(emails) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(emails))
+		for i, email := range emails {
+			ids[i] = email.Id
+		}
+		lease := time.Duration(len(emails))*sendTimeout + time.Minute
+		return tx.Model(&models.OutboxEmail{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(lease)).Error
+	})
+	return emails, err
+}
+
+// retryDelay пауза перед следующей попыткой: 30s * 2^(attempts-1), не более часа
+func retryDelay(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
@@ -1,19 +1,47 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"test/apperr"
+	"test/auth"
+	"test/cli"
 	"test/database"
+	"test/health"
 	"test/logger"
+	"test/mail"
+	"test/metrics"
+	"test/middleware"
+	"test/ratelimit"
 	"test/routes"
+	"test/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/spf13/viper"
 )
 
 func main() {
-	// Инициализация логгера
-	logger.InitLogger()
+	// Конфигурация и логгер загружаются корневой командой до запуска подкоманды
+	if err := cli.NewRootCommand(serve).Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+}
+
+// serve запускает HTTP-сервер
+func serve() error {
+	// Инициализация трассировки
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logger.Logger.Fatalf("Ошибка инициализации трассировки: %v", err)
+	}
 
 	// Подключение к базе данных
 	if err := database.Connect(); err != nil {
@@ -25,16 +53,110 @@ func main() {
 		logger.Logger.Fatalf("Ошибка выполнения миграций: %v", err)
 	}
 
+	// Проверки готовности
+	health.Register("postgres", database.Ping)
+	health.Register("schema_version", database.CheckSchemaVersion)
+
+	// Метрики Prometheus
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		logger.Logger.Fatalf("Ошибка получения пула соединений: %v", err)
+	}
+	metrics.Init(sqlDB)
+	metrics.StartSnapshots()
+
+	// Общее для всех процессов хранилище счетчиков ограничения частоты
+	middleware.RateLimitStore = ratelimit.NewPostgresStore(database.DB)
+
+	// Очистка записей об использованных одноразовых и замененных refresh-токенах
+	auth.StartUsedTokenCleanup(time.Hour)
+	auth.StartSessionCleanup(time.Hour)
+
+	// Отправка писем из очереди
+	if sender := mail.NewSMTPSenderFromConfig(); sender != nil {
+		mail.StartOutboxWorker(database.DB, sender)
+	} else {
+		logger.Logger.Warn("SMTP_HOST не задан, письма остаются в очереди до настройки SMTP")
+	}
+
 	app := fiber.New(fiber.Config{
-		Prefork: true,
+		Prefork:      true,
+		ErrorHandler: apperr.Handler, // Ошибки в формате application/problem+json
+
+		// Большие тела передаются потоком, чтобы загрузка новостей не читала файл в память.
+		// Для остальных маршрутов размер тела ограничивает NewBodyLimitMiddleware.
+		StreamRequestBody: true,
 	})
 
 	app.Use(recover.New())
+	app.Use(middleware.RequestIDMiddleware)
+	app.Use(middleware.LocaleMiddleware)
+	app.Use(middleware.NewBodyLimitMiddleware(middleware.DefaultBodyLimit, "/api/news/import"))
+	app.Use(middleware.TracingMiddleware)
+	app.Use(middleware.MetricsMiddleware)
+	app.Use(middleware.NewAccessLogMiddleware())
 
 	// Регистрация маршрутов
-	routes.RegisterAuthRoutes(app) // Маршруты аутентификации
-	routes.RegisterNewsRoutes(app) // Защищенные маршруты
+	routes.Register(app)
+
+	handleShutdown(app)
 
 	logger.Logger.Info("Приложение запущено")
-	log.Fatal(app.Listen(":9000"))
+	err = app.Listen(":9000")
+
+	// Отправляем накопленные трассировки перед выходом
+	if shutdownErr := shutdownTracing(context.Background()); shutdownErr != nil {
+		logger.Logger.WithError(shutdownErr).Error("Ошибка остановки трассировки")
+	}
+
+	return err
+}
+
+// handleShutdown обрабатывает SIGINT/SIGTERM. Дочерние процессы prefork переводят
+// readiness в состояние отказа, ждут, пока балансировщик это заметит, и завершают
+// обработку запросов. Мастер-процесс пересылает сигнал дочерним процессам.
+func handleShutdown(app *fiber.App) {
+	var (
+		mu       sync.Mutex
+		children []int
+	)
+	app.Hooks().OnFork(func(pid int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		children = append(children, pid)
+		return nil
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		logger.Logger.WithField("signal", sig.String()).Info("Получен сигнал остановки")
+
+		mu.Lock()
+		for _, pid := range children {
+			if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+				logger.Logger.WithError(err).WithField("pid", pid).Warn("Ошибка пересылки сигнала дочернему процессу")
+			}
+		}
+		mu.Unlock()
+
+		health.SetShuttingDown()
+
+		// Даем оркестратору время увидеть отказ readiness
+		drainDelay := viper.GetDuration("SHUTDOWN_DRAIN_DELAY")
+		if drainDelay <= 0 {
+			drainDelay = 5 * time.Second
+		}
+		time.Sleep(drainDelay)
+
+		shutdownTimeout := viper.GetDuration("SHUTDOWN_TIMEOUT")
+		if shutdownTimeout <= 0 {
+			shutdownTimeout = 10 * time.Second
+		}
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			logger.Logger.WithError(err).Error("Ошибка остановки сервера")
+		}
+	}()
 }
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry реестр метрик приложения
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests количество HTTP-запросов по маршруту и статусу
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Количество обработанных HTTP-запросов",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration длительность HTTP-запросов по маршруту и статусу
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Длительность обработки HTTP-запросов",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// NewsCreated количество созданных новостей
+	NewsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "news_created_total",
+		Help: "Количество созданных новостей",
+	})
+
+	// NewsEdited количество отредактированных новостей
+	NewsEdited = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "news_edited_total",
+		Help: "Количество отредактированных новостей",
+	})
+
+	// NewsDeleted количество удаленных новостей
+	NewsDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "news_deleted_total",
+		Help: "Количество удаленных новостей",
+	})
+
+	// LoginAttempts количество попыток входа по результату (success/failure)
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "login_attempts_total",
+		Help: "Количество попыток входа",
+	}, []string{"result"})
+)
+
+// Init регистрирует метрики приложения и статистику пула соединений БД
+func Init(db *sql.DB) {
+	Registry.MustRegister(
+		HTTPRequests,
+		HTTPDuration,
+		NewsCreated,
+		NewsEdited,
+		NewsDeleted,
+		LoginAttempts,
+		collectors.NewDBStatsCollector(db, "news"),
+	)
+}
@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"test/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+)
+
+// В режиме prefork каждый дочерний процесс имеет собственный реестр, а запрос
+// /metrics попадает в случайный процесс. Поэтому каждый процесс периодически
+// сохраняет снимок своих метрик в каталог экземпляра сервера, а обработчик
+// /metrics суммирует снимки живых дочерних процессов этого экземпляра.
+
+var snapshotFormat = expfmt.NewFormat(expfmt.TypeProtoDelim)
+
+// Gatherer возвращает источник метрик с учетом режима prefork
+func Gatherer() prometheus.Gatherer {
+	if !fiber.IsChild() {
+		return Registry
+	}
+	return prometheus.GathererFunc(gatherSnapshots)
+}
+
+// StartSnapshots запускает периодическое сохранение снимков метрик дочернего процесса.
+// Мастер-процесс при запуске удаляет каталог снимков, оставшийся от прежнего
+// экземпляра с тем же PID.
+func StartSnapshots() {
+	if !fiber.IsChild() {
+		if err := os.RemoveAll(snapshotDir()); err != nil {
+			logger.Logger.WithError(err).Warn("Ошибка очистки каталога снимков метрик")
+		}
+		return
+	}
+
+	interval := viper.GetDuration("METRICS_SNAPSHOT_INTERVAL")
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	go func() {
+		for range time.NewTicker(interval).C {
+			if err := writeSnapshot(); err != nil {
+				logger.Logger.WithError(err).Warn("Ошибка сохранения снимка метрик")
+			}
+		}
+	}()
+}
+
+// snapshotDir каталог снимков экземпляра сервера: <METRICS_DIR>/<pid мастер-процесса>.
+// У дочерних процессов мастер — родитель.
+func snapshotDir() string {
+	dir := viper.GetString("METRICS_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "news-app-metrics")
+	}
+	master := os.Getpid()
+	if fiber.IsChild() {
+		master = os.Getppid()
+	}
+	return filepath.Join(dir, strconv.Itoa(master))
+}
+
+// isLiveChild сообщает, что процесс pid жив и является дочерним процессом
+// того же мастера, что и текущий. Так отсеиваются снимки завершившихся процессов,
+// в том числе когда их PID уже занят другим процессом.
+func isLiveChild(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		// Без /proc родителя не проверить, достаточно того, что процесс жив
+		return errors.Is(err, os.ErrNotExist) && !procAvailable()
+	}
+	// Формат: pid (comm) state ppid ...; comm может содержать пробелы и скобки
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 2 {
+		return false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	return err == nil && ppid == os.Getppid()
+}
+
+func procAvailable() bool {
+	_, err := os.Stat("/proc/self/stat")
+	return err == nil
+}
+
+// writeSnapshot атомарно записывает метрики текущего процесса в файл <pid>.prom
+func writeSnapshot() error {
+	families, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	dir := snapshotDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, snapshotFormat)
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+
+	name := filepath.Join(dir, strconv.Itoa(os.Getpid())+".prom")
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// gatherSnapshots читает снимки всех живых процессов и объединяет их
+func gatherSnapshots() ([]*dto.MetricFamily, error) {
+	// Снимок текущего процесса обновляем, чтобы он был актуальным
+	if err := writeSnapshot(); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения снимка метрик: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(snapshotDir(), "*.prom"))
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]*dto.MetricFamily)
+	for _, file := range files {
+		pid, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(file), ".prom"))
+		if err != nil {
+			continue
+		}
+		// Снимки завершившихся процессов удаляем
+		if !isLiveChild(pid) {
+			_ = os.Remove(file)
+			continue
+		}
+		if err := mergeFile(merged, file); err != nil {
+			logger.Logger.WithError(err).WithField("file", file).Warn("Ошибка чтения снимка метрик")
+		}
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]*dto.MetricFamily, 0, len(names))
+	for _, name := range names {
+		result = append(result, merged[name])
+	}
+	return result, nil
+}
+
+func mergeFile(merged map[string]*dto.MetricFamily, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := expfmt.NewDecoder(f, snapshotFormat)
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		existing, ok := merged[mf.GetName()]
+		if !ok {
+			merged[mf.GetName()] = mf
+			continue
+		}
+		mergeFamily(existing, mf)
+	}
+}
+
+// mergeFamily суммирует значения метрик с одинаковым набором меток.
+// Счетчики, гистограммы и статистика пулов соединений аддитивны между процессами.
+func mergeFamily(dst, src *dto.MetricFamily) {
+	index := make(map[string]*dto.Metric, len(dst.Metric))
+	for _, m := range dst.Metric {
+		index[labelsKey(m)] = m
+	}
+
+	for _, m := range src.Metric {
+		existing, ok := index[labelsKey(m)]
+		if !ok {
+			dst.Metric = append(dst.Metric, m)
+			index[labelsKey(m)] = m
+			continue
+		}
+
+		switch dst.GetType() {
+		case dto.MetricType_COUNTER:
+			existing.Counter.Value = proto.Float64(existing.Counter.GetValue() + m.Counter.GetValue())
+		case dto.MetricType_GAUGE:
+			existing.Gauge.Value = proto.Float64(existing.Gauge.GetValue() + m.Gauge.GetValue())
+		case dto.MetricType_UNTYPED:
+			existing.Untyped.Value = proto.Float64(existing.Untyped.GetValue() + m.Untyped.GetValue())
+		case dto.MetricType_HISTOGRAM:
+			mergeHistogram(existing.Histogram, m.Histogram)
+		}
+	}
+}
+
+func mergeHistogram(dst, src *dto.Histogram) {
+	dst.SampleCount = proto.Uint64(dst.GetSampleCount() + src.GetSampleCount())
+	dst.SampleSum = proto.Float64(dst.GetSampleSum() + src.GetSampleSum())
+
+	buckets := make(map[float64]*dto.Bucket, len(dst.Bucket))
+	for _, b := range dst.Bucket {
+		buckets[b.GetUpperBound()] = b
+	}
+	for _, b := range src.Bucket {
+		if existing, ok := buckets[b.GetUpperBound()]; ok {
+			existing.CumulativeCount = proto.Uint64(existing.GetCumulativeCount() + b.GetCumulativeCount())
+		}
+	}
+}
+
+func labelsKey(m *dto.Metric) string {
+	pairs := make([]string, 0, len(m.Label))
+	for _, l := range m.Label {
+		pairs = append(pairs, l.GetName()+"="+l.GetValue())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"test/config"
+	"test/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatCombined = "combined"
+)
+
+// NewAccessLogMiddleware создает middleware журнала доступа. Формат задается ACCESS_LOG_FORMAT:
+// json (через logger.Logger) или combined (формат Apache combined в stdout).
+func NewAccessLogMiddleware() fiber.Handler {
+	format := viper.GetString("ACCESS_LOG_FORMAT")
+	if format == "" {
+		format = AccessLogFormatJSON
+	}
+	logHeaders := viper.GetBool("ACCESS_LOG_HEADERS")
+	logBody := viper.GetBool("ACCESS_LOG_BODY")
+
+	redactor := logger.NewRedactor(
+		config.GetList("ACCESS_LOG_REDACT_HEADERS", "Authorization", "Cookie", "Set-Cookie", "X-API-Key"),
+		config.GetList("ACCESS_LOG_REDACT_FIELDS",
+			"password", "token", "refresh_token", "secret", "client_secret",
+			"code", "recovery_code", "challenge_token", "state",
+		),
+	)
+
+	var out io.Writer = os.Stdout
+
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// Ошибку отрисовываем здесь, чтобы в логе были итоговые статус и размер ответа
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+			err = nil
+		}
+		latency := time.Since(start)
+
+		status := c.Response().StatusCode()
+
+		user, _ := c.Locals("user_id").(string)
+
+		if format == AccessLogFormatCombined {
+			writeCombined(out, c, redactor, status, user, start)
+			return err
+		}
+
+		fields := logrus.Fields{
+			"type":       "access",
+			"path":       c.Path(),
+			"status":     status,
+			"latency_ms": float64(latency.Microseconds()) / 1000,
+			"bytes":      len(c.Response().Body()),
+			"ip":         c.IP(),
+			"user_agent": c.Get(fiber.HeaderUserAgent),
+		}
+		if user != "" {
+			fields["user"] = user
+		}
+		if logHeaders {
+			headers := make(map[string]string)
+			c.Request().Header.VisitAll(func(key, value []byte) {
+				headers[string(key)] = redactor.Header(string(key), string(value))
+			})
+			fields["headers"] = headers
+		}
+		if logBody {
+			if body := redactor.Body(c.Body()); body != nil {
+				fields["body"] = body
+			}
+		}
+
+		logger.Ctx(c).WithFields(fields).Info("HTTP-запрос обработан")
+		return err
+	}
+}
+
+// writeCombined пишет строку в формате Apache combined. Чувствительные параметры
+// запроса в адресе скрываются.
+func writeCombined(out io.Writer, c *fiber.Ctx, redactor *logger.Redactor, status int, user string, start time.Time) {
+	if user == "" {
+		user = "-"
+	}
+	size := "-"
+	if n := len(c.Response().Body()); n > 0 {
+		size = strconv.Itoa(n)
+	}
+	referer := c.Get(fiber.HeaderReferer)
+	if referer == "" {
+		referer = "-"
+	}
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if userAgent == "" {
+		userAgent = "-"
+	}
+
+	fmt.Fprintf(out, "%s - %s [%s] \"%s %s %s\" %d %s %q %q\n",
+		c.IP(),
+		user,
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		c.Method(),
+		redactor.URL(c.OriginalURL()),
+		c.Request().Header.Protocol(),
+		status,
+		size,
+		referer,
+		userAgent,
+	)
+}
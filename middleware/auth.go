@@ -4,62 +4,149 @@ import (
 	"errors"
 	"strings"
 
+	"test/apperr"
+	"test/auth"
 	"test/logger"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/spf13/viper"
+	"github.com/sirupsen/logrus"
 )
 
 // AuthMiddleware проверяет наличие и валидность JWT-токена в заголовке Authorization
+// или API-ключа (заголовок X-API-Key либо Authorization: ApiKey <ключ>)
 func AuthMiddleware(c *fiber.Ctx) error {
+	log := logger.Ctx(c)
+
+	// API-ключ сервисной учетной записи
+	if key := apiKeyFromRequest(c); key != "" {
+		return authenticateAPIKey(c, key)
+	}
+
 	// Извлекаем заголовок Authorization
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
-		logger.Logger.Warn("Заголовок Authorization отсутствует")
-		return c.Status(401).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Unauthorized: Missing Authorization header",
-		})
+		log.Warn("Заголовок Authorization отсутствует")
+		return apperr.New(apperr.CodeUnauthorized)
 	}
 
 	// Проверяем формат заголовка (Bearer <token>)
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	if tokenString == authHeader || tokenString == "" {
-		logger.Logger.Warn("Неверный формат заголовка Authorization")
-		return c.Status(401).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Unauthorized: Invalid Authorization format",
-		})
+		log.Warn("Неверный формат заголовка Authorization")
+		return apperr.New(apperr.CodeTokenInvalid)
 	}
 
-	// Получаем секретный ключ из переменной окружения
-	jwtSecret := viper.GetString("JWT_SECRET")
-	if jwtSecret == "" {
-		logger.Logger.Error("JWT_SECRET не задан в переменных окружения")
-		return c.Status(500).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Internal Server Error: JWT_SECRET is not set",
-		})
+	// Парсим и проверяем токен
+	claims, err := auth.ParseToken(tokenString)
+	if errors.Is(err, auth.ErrSecretNotSet) {
+		log.Error("JWT_SECRET не задан в переменных окружения")
+		return apperr.New(apperr.CodeInternal)
+	}
+	if errors.Is(err, auth.ErrTokenExpired) {
+		log.Info("Срок действия JWT-токена истек")
+		return apperr.New(apperr.CodeTokenExpired)
+	}
+	if err != nil {
+		log.WithError(err).Warn("Невалидный JWT-токен")
+		return apperr.New(apperr.CodeTokenInvalid)
 	}
 
-	// Парсим и проверяем токен
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Проверяем алгоритм подписи
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+	// Идентифицируем пользователя для обработчиков и логов
+	principal, ok := auth.PrincipalFromClaims(claims)
+	if !ok {
+		log.Warn("JWT-токен не является токеном доступа")
+		return apperr.New(apperr.CodeTokenInvalid)
+	}
+
+	// Токен отозванного сеанса больше не действует
+	if principal.SessionID != 0 {
+		err := auth.CheckSession(c.UserContext(), principal.SessionID)
+		if errors.Is(err, auth.ErrSessionRevoked) {
+			log.Warn("Сеанс JWT-токена отозван")
+			return apperr.New(apperr.CodeSessionRevoked)
 		}
-		return []byte(jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		logger.Logger.WithError(err).Warn("Невалидный JWT-токен")
-		return c.Status(401).JSON(fiber.Map{
-			"Success": false,
-			"Message": "Unauthorized: Invalid token",
-		})
+		if err != nil {
+			log.WithError(err).Error("Ошибка проверки сеанса")
+			return apperr.New(apperr.CodeInternal)
+		}
+	}
+
+	auth.SetPrincipal(c, principal)
+	logger.AddFields(c, logrus.Fields{"user_id": principal.Username})
+
+	logger.Ctx(c).Info("JWT-токен успешно проверен")
+	return c.Next()
+}
+
+// apiKeyFromRequest извлекает API-ключ из заголовков запроса
+func apiKeyFromRequest(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if key, ok := strings.CutPrefix(c.Get("Authorization"), "ApiKey "); ok {
+		return key
+	}
+	return ""
+}
+
+func authenticateAPIKey(c *fiber.Ctx, key string) error {
+	log := logger.Ctx(c)
+
+	principal, err := auth.AuthenticateAPIKey(c.UserContext(), key, c.IP())
+	switch {
+	case errors.Is(err, auth.ErrInvalidAPIKey), errors.Is(err, auth.ErrAPIKeyNotAllowed):
+		log.WithError(err).Warn("Невалидный API-ключ")
+		return apperr.New(apperr.CodeAPIKeyInvalid)
+	case err != nil:
+		log.WithError(err).Error("Ошибка проверки API-ключа")
+		return apperr.New(apperr.CodeInternal)
 	}
 
-	logger.Logger.Info("JWT-токен успешно проверен")
+	auth.SetPrincipal(c, principal)
+	logger.AddFields(c, logrus.Fields{"user_id": principal.Username, "api_key_id": principal.APIKeyID})
+
+	logger.Ctx(c).Info("API-ключ успешно проверен")
 	return c.Next()
 }
+
+// RequireScope разрешает доступ только с указанной областью доступа API-ключа.
+// Используется после AuthMiddleware.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal := auth.PrincipalFrom(c)
+		if principal != nil && principal.HasScope(scope) {
+			return c.Next()
+		}
+
+		logger.Ctx(c).WithField("scope", scope).Warn("У ключа нет требуемой области доступа")
+		return apperr.New(apperr.CodeScopeMissing).WithDetail("detail.scope_required", scope)
+	}
+}
+
+// RequireRole разрешает доступ только пользователям с одной из указанных ролей.
+// Используется после AuthMiddleware.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		for _, allowed := range roles {
+			if role == allowed {
+				return c.Next()
+			}
+		}
+
+		logger.Ctx(c).WithField("role", role).Warn("Недостаточно прав для доступа")
+		return apperr.New(apperr.CodeForbidden)
+	}
+}
+
+// RequireMFA требует вход со вторым фактором для ролей из политики MFA_REQUIRED_ROLES.
+// Используется после AuthMiddleware.
+func RequireMFA(c *fiber.Ctx) error {
+	principal := auth.PrincipalFrom(c)
+	if principal == nil || principal.MFA || !auth.MFARequired(principal.Role) {
+		return c.Next()
+	}
+
+	logger.Ctx(c).WithField("role", principal.Role).Warn("Для роли требуется двухфакторная аутентификация")
+	return apperr.New(apperr.CodeMFARequired)
+}
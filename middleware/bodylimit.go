@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultBodyLimit наибольший размер тела запроса, кроме маршрутов потоковой загрузки
+const DefaultBodyLimit = 4 * 1024 * 1024
+
+// NewBodyLimitMiddleware ограничивает размер тела запроса. С StreamRequestBody Fiber не
+// отклоняет большие тела, а отдает их потоком, поэтому лимит проверяется здесь: тело
+// дочитывается в память не больше чем до limit байт. Для путей из streaming тело
+// остается потоком, его читает обработчик.
+func NewBodyLimitMiddleware(limit int, streaming ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if slices.Contains(streaming, strings.TrimRight(c.Path(), "/")) {
+			return c.Next()
+		}
+		if c.Request().Header.ContentLength() > limit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+
+		if c.Request().IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(limit)+1))
+			if err != nil {
+				return fiber.ErrBadRequest
+			}
+			if len(body) > limit {
+				return fiber.ErrRequestEntityTooLarge
+			}
+			c.Request().SetBody(body)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"test/i18n"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocaleMiddleware выбирает язык ответа по заголовку Accept-Language
+// и сохраняет его в Locals("lang") для обработчиков и ErrorHandler
+func LocaleMiddleware(c *fiber.Ctx) error {
+	lang := i18n.Negotiate(c.Get(fiber.HeaderAcceptLanguage))
+	c.Locals("lang", lang)
+
+	c.Vary(fiber.HeaderAcceptLanguage)
+	c.Set(fiber.HeaderContentLanguage, lang)
+
+	return c.Next()
+}
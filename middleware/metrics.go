@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"test/apperr"
+	"test/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware считает количество и длительность запросов по маршруту и статусу
+func MetricsMiddleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apperr.StatusOf(err)
+	}
+
+	// Для запросов, не попавших ни в один маршрут, не плодим метки по произвольным путям
+	route := c.Route().Path
+	if c.Route().Method == "USE" {
+		route = "unmatched"
+	}
+
+	labels := []string{c.Method(), route, strconv.Itoa(status)}
+	metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+	metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+	return err
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/apperr"
+	"test/logger"
+	"test/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// RateLimitStore общее хранилище счетчиков, задается при запуске приложения
+var RateLimitStore ratelimit.Store
+
+// RateLimitKey возвращает ключ, по которому считаются запросы
+type RateLimitKey func(c *fiber.Ctx) string
+
+// KeyByIP считает запросы по IP клиента
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// KeyByUser считает запросы по пользователю, а для анонимных запросов — по IP
+func KeyByUser(c *fiber.Ctx) string {
+	if user, ok := c.Locals("user_id").(string); ok && user != "" {
+		return "user:" + user
+	}
+	return KeyByIP(c)
+}
+
+// KeyByLoginUsername считает запросы по имени пользователя из тела запроса на вход.
+// Тело разбирается BodyParser, как и в обработчике: JSON, XML и формы.
+func KeyByLoginUsername(c *fiber.Ctx) string {
+	var req struct {
+		Username string `json:"username" xml:"username" form:"username"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Username == "" {
+		return ""
+	}
+	return "username:" + strings.ToLower(req.Username)
+}
+
+// RateLimitCost возвращает, сколько раз засчитать запрос
+type RateLimitCost func(c *fiber.Ctx) int
+
+// CostByBatchOperations засчитывает пакетный запрос по числу операций в теле, чтобы
+// пакет расходовал лимит так же, как отдельные запросы. Тело, которое не удалось
+// разобрать, засчитывается один раз: его отклонит проверка в обработчике.
+func CostByBatchOperations(c *fiber.Ctx) int {
+	var req struct {
+		Operations []json.RawMessage `json:"Operations"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil || len(req.Operations) == 0 {
+		return 1
+	}
+	return len(req.Operations)
+}
+
+// NewRateLimiter создает middleware ограничения частоты по правилу из переменной configKey
+// (например "10/1m"). Если переменная не задана, используется правило по умолчанию.
+func NewRateLimiter(name, configKey, defaultRule string, key RateLimitKey) fiber.Handler {
+	return NewWeightedRateLimiter(name, configKey, defaultRule, key, nil)
+}
+
+// NewWeightedRateLimiter создает middleware ограничения частоты, как NewRateLimiter, но
+// засчитывает запрос cost(c) раз. Ограничители с одинаковым name расходуют общий счетчик.
+func NewWeightedRateLimiter(name, configKey, defaultRule string, key RateLimitKey, cost RateLimitCost) fiber.Handler {
+	raw := defaultRule
+	if viper.IsSet(configKey) {
+		raw = viper.GetString(configKey)
+	}
+	rule, err := ratelimit.ParseRule(raw)
+	if err != nil {
+		logger.Logger.Fatalf("Ошибка настройки ограничения частоты %s: %v", configKey, err)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if !rule.Enabled() || RateLimitStore == nil {
+			return c.Next()
+		}
+
+		id := key(c)
+		if id == "" {
+			return c.Next()
+		}
+
+		n := 1
+		if cost != nil {
+			n = cost(c)
+		}
+
+		count, reset, err := RateLimitStore.Hit(c.UserContext(), name+":"+id, rule.Window, n)
+		if err != nil {
+			// При недоступности хранилища не блокируем запросы
+			logger.Ctx(c).WithError(err).Error("Ошибка проверки ограничения частоты")
+			return c.Next()
+		}
+
+		remaining := rule.Limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		resetSeconds := int(math.Ceil(time.Until(reset).Seconds()))
+		if resetSeconds < 0 {
+			resetSeconds = 0
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+		c.Set("RateLimit-Policy", strconv.Itoa(rule.Limit)+";w="+strconv.Itoa(int(rule.Window.Seconds())))
+
+		if count > rule.Limit {
+			logger.Ctx(c).WithFields(logrus.Fields{
+				"limit": name,
+				"key":   id,
+			}).Warn("Превышено ограничение частоты запросов")
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetSeconds))
+			return apperr.New(apperr.CodeRateLimited)
+		}
+
+		return c.Next()
+	}
+}
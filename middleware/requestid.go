@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"test/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора, присланного клиентом
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware принимает X-Request-ID от клиента или генерирует новый,
+// возвращает его в ответе и создает логгер запроса
+func RequestIDMiddleware(c *fiber.Ctx) error {
+	requestID := c.Get(RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = uuid.NewString()
+	}
+
+	c.Set(RequestIDHeader, requestID)
+	c.Locals("request_id", requestID)
+
+	c.SetUserContext(logger.NewContext(c.UserContext(), logger.Logger.WithFields(logrus.Fields{
+		"request_id": requestID,
+		"method":     c.Method(),
+	})))
+
+	return c.Next()
+}
+
+// validRequestID допускает только непустые идентификаторы из печатных ASCII-символов
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
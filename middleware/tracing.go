@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"test/apperr"
+	"test/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier адаптирует заголовки Fiber к propagation.TextMapCarrier
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// TracingMiddleware создает span для каждого запроса, продолжая трассировку из заголовка traceparent
+func TracingMiddleware(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+
+	ctx, span := tracing.Tracer().Start(ctx, c.Method(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Method()),
+			attribute.String("url.path", c.Path()),
+			attribute.String("client.address", c.IP()),
+		),
+	)
+	defer span.End()
+
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	// Маршрут известен только после сопоставления
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttributes(attribute.String("http.route", c.Route().Path))
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		span.RecordError(err)
+		status = apperr.StatusOf(err)
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, "")
+	}
+
+	return err
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Области доступа API-ключей
+const (
+	ScopeNewsRead  = "news:read"
+	ScopeNewsWrite = "news:write"
+)
+
+// APIKey ключ доступа сервисной учетной записи. Сам ключ показывается один раз при
+// создании, в БД хранится только его хеш.
+type APIKey struct {
+	Id             uint       `gorm:"primaryKey;autoIncrement" json:"Id"`
+	Name           string     `gorm:"size:128;not null" json:"Name"`
+	ServiceAccount string     `gorm:"size:64;not null;index" json:"ServiceAccount"`
+	Prefix         string     `gorm:"size:16;not null;uniqueIndex" json:"Prefix"` // Открытая часть ключа для поиска
+	KeyHash        string     `gorm:"size:64;not null" json:"-"`
+	Scopes         string     `gorm:"size:512;not null" json:"-"` // Области доступа через запятую
+	AllowedIPs     string     `gorm:"size:1024" json:"-"`         // IP-адреса и подсети через запятую, пусто — без ограничений
+	ExpiresAt      *time.Time `json:"ExpiresAt"`
+	RevokedAt      *time.Time `json:"RevokedAt"`
+	LastUsedAt     *time.Time `json:"LastUsedAt"`
+	CreatedBy      string     `gorm:"size:255" json:"CreatedBy"`
+	CreatedAt      time.Time  `json:"CreatedAt"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// AuditEvent запись журнала аудита. Записи только добавляются: изменение и удаление
+// запрещены триггером, а цепочка хешей позволяет обнаружить подмену.
+type AuditEvent struct {
+	Id         uint      `gorm:"primaryKey;autoIncrement" json:"Id"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"CreatedAt"`
+	Actor      string    `gorm:"size:255;not null;index" json:"Actor"`
+	ActorId    *uint     `json:"ActorId"`
+	Action     string    `gorm:"size:64;not null;index" json:"Action"`
+	TargetType string    `gorm:"size:32;not null;index:idx_audit_events_target" json:"TargetType"`
+	TargetId   string    `gorm:"size:255;not null;index:idx_audit_events_target" json:"TargetId"`
+	Before     string    `gorm:"type:text" json:"Before"` // Состояние до изменения (JSON)
+	After      string    `gorm:"type:text" json:"After"`  // Состояние после изменения (JSON)
+	IP         string    `gorm:"size:64" json:"IP"`
+	RequestId  string    `gorm:"size:128;index" json:"RequestId"`
+	PrevHash   string    `gorm:"size:64;not null" json:"PrevHash"`
+	Hash       string    `gorm:"size:64;not null;uniqueIndex" json:"Hash"`
+	Keyed      bool      `gorm:"not null;default:false" json:"Keyed"` // Hash — HMAC с ключом вне БД; false у записей до введения ключа
+}
+
+// Действия журнала аудита
+const (
+	AuditNewsCreate       = "news.create"
+	AuditNewsEdit         = "news.edit"
+	AuditNewsDelete       = "news.delete"
+	AuditNewsCategories   = "news.categories"
+	AuditLogin            = "auth.login"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditUserCreate       = "user.create"
+	AuditRoleChange       = "user.role_change"
+	AuditUserDisable      = "user.disable"
+	AuditPasswordReset    = "user.password_reset"
+	AuditSessionRevoke    = "session.revoke"
+	AuditSessionRevokeAll = "session.revoke_all"
+	AuditTokenIssue       = "auth.token_issue"
+	AuditCategoryCreate   = "category.create"
+	AuditCategoryUpdate   = "category.update"
+	AuditCategoryDelete   = "category.delete"
+	AuditAPIKeyCreate     = "apikey.create"
+	AuditAPIKeyRotate     = "apikey.rotate"
+	AuditAPIKeyRevoke     = "apikey.revoke"
+)
+
+// Типы объектов журнала аудита
+const (
+	AuditTargetNews     = "news"
+	AuditTargetUser     = "user"
+	AuditTargetCategory = "category"
+	AuditTargetAPIKey   = "apikey"
+)
@@ -0,0 +1,10 @@
+package models
+
+// Category категория новостей. Категории образуют дерево: ParentId указывает
+// на родительскую категорию, у корневых категорий он пустой.
+type Category struct {
+	Id       uint      `gorm:"primaryKey;autoIncrement" json:"Id"`
+	Name     string    `gorm:"size:255;not null" json:"Name"`
+	ParentId *uint     `gorm:"index" json:"ParentId"`
+	Parent   *Category `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+}
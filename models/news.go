@@ -5,6 +5,21 @@ type News struct {
 	Id      uint   `gorm:"primaryKey;autoIncrement" json:"Id"`
 	Title   string `gorm:"size:255;not null" json:"Title"`
 	Content string `gorm:"type:text;not null" json:"Content"`
+
+	// ExternalId идентификатор новости во внешней системе (например, в старой CMS),
+	// по нему повторная загрузка обновляет новость, а не создает копию
+	ExternalId *string `gorm:"size:255;uniqueIndex" json:"ExternalId"`
+
+	// Автор и последний редактор. Id пустой у пользователей не из БД
+	// (тестовый пользователь, сервисные учетные записи), имя сохраняется всегда.
+	AuthorId       *uint  `gorm:"index" json:"AuthorId"`
+	AuthorName     string `gorm:"size:255" json:"AuthorName"`
+	LastEditorId   *uint  `json:"LastEditorId"`
+	LastEditorName string `gorm:"size:255" json:"LastEditorName"`
+
+	// AuthorExternal автор из загруженного архива без учетной записи: имя только
+	// отображается и прав на новость не дает
+	AuthorExternal bool `gorm:"not null;default:false" json:"AuthorExternal"`
 }
 
 // NewsCategory представляет связь между новостями и категориями
@@ -13,10 +28,111 @@ type NewsCategory struct {
 	CategoryId uint `gorm:"primaryKey"`
 }
 
-// NewsResponse представляет ответ для клиента (JSON)
-type NewsResponse struct {
-	Id         uint   `json:"Id"`
-	Title      string `json:"Title"`
-	Content    string `json:"Content"`
-	Categories []uint `json:"Categories"` // Список ID категорий
+// NewsCoauthor соавтор новости: может редактировать ее наравне с автором
+type NewsCoauthor struct {
+	NewsId uint `gorm:"primaryKey"`
+	UserId uint `gorm:"primaryKey;index"`
+}
+
+// NewsAuthor сведения об авторе, редакторе или соавторе в ответах
+type NewsAuthor struct {
+	Id       *uint  `json:"Id"`
+	Username string `json:"Username"`
+}
+
+// NewsSchemaVersion версия схемы NewsView в ответах. Увеличивается при несовместимых
+// изменениях представления; добавление полей версию не меняет.
+const NewsSchemaVersion = 1
+
+// NewsView представление новости в ответах (JSON)
+type NewsView struct {
+	Id         uint    `json:"Id"`
+	Title      string  `json:"Title"`
+	Content    string  `json:"Content"`
+	Categories []uint  `json:"Categories"` // Список ID категорий
+	ExternalId *string `json:"ExternalId,omitempty"`
+
+	Author     *NewsAuthor  `json:"Author"`
+	LastEditor *NewsAuthor  `json:"LastEditor"`
+	CoAuthors  []NewsAuthor `json:"CoAuthors"`
+}
+
+// NewNewsView переносит в представление поля самой новости. Категории и соавторы
+// загружаются отдельно и изначально пусты.
+func NewNewsView(news News) NewsView {
+	return NewsView{
+		Id:         news.Id,
+		Title:      news.Title,
+		Content:    news.Content,
+		Categories: []uint{},
+		ExternalId: news.ExternalId,
+		Author:     newsAuthor(news.AuthorId, news.AuthorName),
+		LastEditor: newsAuthor(news.LastEditorId, news.LastEditorName),
+		CoAuthors:  []NewsAuthor{},
+	}
+}
+
+// newsAuthor сведения о пользователе; nil, если о нем ничего не сохранено
+func newsAuthor(id *uint, name string) *NewsAuthor {
+	if id == nil && name == "" {
+		return nil
+	}
+	return &NewsAuthor{Id: id, Username: name}
+}
+
+// CreateNewsRequest тело запроса на создание новости
+type CreateNewsRequest struct {
+	Title       string `json:"Title" validate:"required,max=255"`
+	Content     string `json:"Content" validate:"required"`
+	Categories  []uint `json:"Categories" validate:"max=50,unique,dive,gt=0"`
+	CoAuthorIds []uint `json:"CoAuthorIds" validate:"max=50,unique,dive,gt=0"`
+}
+
+// News новость из запроса; автор заполняется обработчиком
+func (r *CreateNewsRequest) News() News {
+	return News{Title: r.Title, Content: r.Content}
+}
+
+// UpdateNewsRequest тело запроса на редактирование новости
+type UpdateNewsRequest struct {
+	Title       string  `json:"Title" validate:"required,max=255"`
+	Content     string  `json:"Content" validate:"required"`
+	Categories  []uint  `json:"Categories" validate:"max=50,unique,dive,gt=0"`
+	CoAuthorIds *[]uint `json:"CoAuthorIds" validate:"omitempty,max=50,unique,dive,gt=0"` // Без поля соавторы не меняются
+}
+
+// Changes изменяемые колонки новости; последний редактор заполняется обработчиком
+func (r *UpdateNewsRequest) Changes() map[string]interface{} {
+	return map[string]interface{}{
+		"title":   r.Title,
+		"content": r.Content,
+	}
+}
+
+// Режимы пакетной записи новостей
+const (
+	BatchAtomic     = "atomic"      // Все операции или ни одной
+	BatchBestEffort = "best_effort" // Успешные операции сохраняются, неудачные пропускаются
+)
+
+// Операции пакетной записи новостей
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// NewsBatchRequest тело запроса пакетной записи новостей
+type NewsBatchRequest struct {
+	Mode       string               `json:"Mode" validate:"omitempty,oneof=atomic best_effort"` // По умолчанию atomic
+	Operations []NewsBatchOperation `json:"Operations" validate:"required,min=1,max=500,dive"`
+}
+
+// NewsBatchOperation операция пакета: create с телом Create, update с Id и телом Update
+// или delete с Id
+type NewsBatchOperation struct {
+	Op     string             `json:"Op" validate:"required,oneof=create update delete"`
+	Id     uint               `json:"Id" validate:"required_unless=Op create"`
+	Create *CreateNewsRequest `json:"Create" validate:"required_if=Op create"`
+	Update *UpdateNewsRequest `json:"Update" validate:"required_if=Op update"`
 }
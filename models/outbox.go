@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OutboxEmail письмо в очереди на отправку. Письма сохраняются в той же транзакции,
+// что и изменения, и отправляются фоновым обработчиком с повторами.
+type OutboxEmail struct {
+	Id            uint       `gorm:"primaryKey;autoIncrement"`
+	To            string     `gorm:"size:255;not null"`
+	Subject       string     `gorm:"size:255;not null"`
+	Body          string     `gorm:"type:text;not null"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"not null;index"`
+	SentAt        *time.Time `gorm:"index"`
+	LastError     string     `gorm:"type:text"`
+	CreatedAt     time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RateLimitCounter счетчик запросов в окне ограничения частоты.
+// Хранится в БД, чтобы лимиты были общими для всех процессов prefork.
+type RateLimitCounter struct {
+	Key         string    `gorm:"primaryKey;size:255"`
+	WindowStart time.Time `gorm:"primaryKey"`
+	Count       int       `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// SchemaMigration хранит версию схемы БД, до которой были выполнены миграции
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"not null"`
+}
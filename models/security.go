@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// LoginFailure неудачные попытки входа по ключу (имя пользователя или IP)
+type LoginFailure struct {
+	Key           string     `gorm:"primaryKey;size:255"`
+	Failures      int        `gorm:"not null"`
+	LastFailureAt time.Time  `gorm:"not null"`
+	NextAttemptAt time.Time  `gorm:"not null"`
+	LockedUntil   *time.Time `gorm:"index"`
+}
+
+// LoginFailureSource IP-адрес, с которого были неудачные попытки входа в учетную запись.
+// По нему разблокировка учетной записи снимает и блокировки этих IP.
+type LoginFailureSource struct {
+	Key string `gorm:"primaryKey;size:255"`
+	IP  string `gorm:"primaryKey;size:64"`
+}
+
+// SecurityEvent событие безопасности (блокировка, разблокировка учетной записи)
+type SecurityEvent struct {
+	Id        uint      `gorm:"primaryKey;autoIncrement" json:"Id"`
+	Type      string    `gorm:"size:64;not null;index" json:"Type"`
+	Subject   string    `gorm:"size:255;not null;index" json:"Subject"`
+	Actor     string    `gorm:"size:255" json:"Actor"`
+	IP        string    `gorm:"size:64" json:"IP"`
+	Details   string    `gorm:"type:text" json:"Details"`
+	CreatedAt time.Time `gorm:"not null;index" json:"CreatedAt"`
+}
+
+const (
+	SecurityEventLockout = "lockout"
+	SecurityEventUnlock  = "unlock"
+	// SecurityEventRefreshReuse повторное предъявление замененного refresh-токена
+	SecurityEventRefreshReuse = "refresh_token_reuse"
+)
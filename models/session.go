@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Session сеанс входа пользователя. Каждому сеансу соответствует refresh-токен,
+// в БД хранится только его хеш.
+type Session struct {
+	Id               uint       `gorm:"primaryKey;autoIncrement" json:"Id"`
+	UserId           uint       `gorm:"index" json:"UserId"` // 0 для тестового пользователя из переменных окружения
+	Username         string     `gorm:"size:255;not null;index" json:"Username"`
+	Role             string     `gorm:"size:32;not null" json:"-"`
+	MFA              bool       `gorm:"not null;default:false" json:"MFA"`
+	Device           string     `gorm:"size:128" json:"Device"`
+	IP               string     `gorm:"size:64" json:"IP"`
+	UserAgent        string     `gorm:"size:512" json:"UserAgent"`
+	RefreshTokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	CreatedAt        time.Time  `json:"CreatedAt"`
+	LastSeenAt       time.Time  `gorm:"not null" json:"LastSeenAt"`
+	ExpiresAt        time.Time  `gorm:"not null" json:"ExpiresAt"`
+	RevokedAt        *time.Time `gorm:"index" json:"RevokedAt"`
+
+	Current bool `gorm:"-" json:"Current"` // Сеанс текущего запроса
+}
+
+// RotatedRefreshToken хеш refresh-токена, уже замененного новым. Предъявление такого
+// токена означает, что он скопирован, и сеанс отзывается.
+type RotatedRefreshToken struct {
+	Hash      string    `gorm:"primaryKey;size:64"`
+	SessionId uint      `gorm:"not null;index"`
+	RotatedAt time.Time `gorm:"not null;index"`
+}
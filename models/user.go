@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Роли пользователей
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// User учетная запись пользователя
+type User struct {
+	Id            uint      `gorm:"primaryKey;autoIncrement" json:"Id"`
+	Username      string    `gorm:"size:64;not null;uniqueIndex" json:"Username"`
+	Email         string    `gorm:"size:255;not null;uniqueIndex" json:"Email"`
+	PasswordHash  string    `gorm:"size:255;not null" json:"-"`
+	Role          string    `gorm:"size:32;not null;default:viewer" json:"Role"`
+	EmailVerified bool      `gorm:"not null;default:false" json:"EmailVerified"`
+	Disabled      bool      `gorm:"not null;default:false" json:"Disabled"`
+	TOTPSecret    string    `gorm:"size:64" json:"-"`
+	TOTPEnabled   bool      `gorm:"not null;default:false" json:"TotpEnabled"`
+	TOTPLastStep  int64     `gorm:"not null;default:0" json:"-"`     // Последний принятый шаг TOTP, защищает от повтора кода
+	OIDCSubject   *string   `gorm:"size:255;uniqueIndex" json:"-"`   // issuer|sub учетной записи у OIDC-провайдера
+	OIDCManaged   bool      `gorm:"not null;default:false" json:"-"` // Создана при входе через OIDC, роль берется из групп провайдера
+	CreatedAt     time.Time `json:"CreatedAt"`
+	UpdatedAt     time.Time `json:"UpdatedAt"`
+}
+
+// UsedToken идентификатор уже использованного одноразового токена
+type UsedToken struct {
+	Jti       string    `gorm:"primaryKey;size:64"`
+	UsedAt    time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// RecoveryCode одноразовый код восстановления доступа при утере устройства с TOTP
+type RecoveryCode struct {
+	Id       uint   `gorm:"primaryKey;autoIncrement"`
+	UserId   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"size:64;not null;index"`
+	UsedAt   *time.Time
+}
@@ -0,0 +1,147 @@
+// Package newsdb содержит операции над новостями в транзакции, общие для обработчиков
+// запросов и загрузки из файлов: права на новость, представления, категории и журнал аудита.
+package newsdb
+
+import (
+	"slices"
+	"strconv"
+
+	"test/audit"
+	"test/auth"
+	"test/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// EditorRole сообщает, может ли роль изменять любые новости, а не только свои
+func EditorRole(role string) bool {
+	return role == models.RoleAdmin || role == models.RoleEditor
+}
+
+// IsAuthor сообщает, является ли пользователь автором новости. Пользователи не из БД
+// сопоставляются по имени; имя автора из загруженного архива прав не дает.
+func IsAuthor(news *models.News, p *auth.Principal) bool {
+	if p.UserID != 0 {
+		return news.AuthorId != nil && *news.AuthorId == p.UserID
+	}
+	return news.AuthorId == nil && !news.AuthorExternal && news.AuthorName != "" && news.AuthorName == p.Username
+}
+
+// CanEdit разрешает редактирование автору, соавторам и ролям с правом редактирования всех новостей
+func CanEdit(tx *gorm.DB, news *models.News, p *auth.Principal) (bool, error) {
+	if EditorRole(p.Role) || IsAuthor(news, p) {
+		return true, nil
+	}
+	if p.UserID == 0 {
+		return false, nil
+	}
+
+	var count int64
+	err := tx.Model(&models.NewsCoauthor{}).Where("news_id = ? AND user_id = ?", news.Id, p.UserID).Count(&count).Error
+	return count > 0, err
+}
+
+// SetCategories назначает категории новости одной многострочной вставкой.
+// Прежние категории удаляет вызывающий.
+func SetCategories(tx *gorm.DB, newsID uint, categoryIDs []uint) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	rows := make([]models.NewsCategory, len(categoryIDs))
+	for i, id := range categoryIDs {
+		rows[i] = models.NewsCategory{NewsId: newsID, CategoryId: id}
+	}
+	return tx.Create(&rows).Error
+}
+
+// Views собирает представления новостей: категории, автор, последний редактор и соавторы
+func Views(db *gorm.DB, newsList []models.News) ([]models.NewsView, error) {
+	result := make([]models.NewsView, len(newsList))
+	if len(newsList) == 0 {
+		return result, nil
+	}
+
+	ids := make([]uint, len(newsList))
+	index := make(map[uint]*models.NewsView, len(newsList))
+	for i, news := range newsList {
+		ids[i] = news.Id
+		result[i] = models.NewNewsView(news)
+		index[news.Id] = &result[i]
+	}
+
+	var categories []models.NewsCategory
+	if err := db.Where("news_id IN ?", ids).Order("category_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		index[category.NewsId].Categories = append(index[category.NewsId].Categories, category.CategoryId)
+	}
+
+	var coauthors []struct {
+		NewsId   uint
+		UserId   uint
+		Username string
+	}
+	err := db.Model(&models.NewsCoauthor{}).
+		Select("news_coauthors.news_id, news_coauthors.user_id, users.username").
+		Joins("JOIN users ON users.id = news_coauthors.user_id").
+		Where("news_coauthors.news_id IN ?", ids).
+		Order("users.username").
+		Scan(&coauthors).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, coauthor := range coauthors {
+		id := coauthor.UserId
+		index[coauthor.NewsId].CoAuthors = append(index[coauthor.NewsId].CoAuthors, models.NewsAuthor{Id: &id, Username: coauthor.Username})
+	}
+
+	return result, nil
+}
+
+// Snapshot состояние новости с категориями и авторами для журнала аудита
+func Snapshot(tx *gorm.DB, newsID uint) (*models.NewsView, error) {
+	var news models.News
+	if err := tx.First(&news, newsID).Error; err != nil {
+		return nil, err
+	}
+	result, err := Views(tx, []models.News{news})
+	if err != nil {
+		return nil, err
+	}
+	return &result[0], nil
+}
+
+// RecordAudit записывает изменение новости в журнал аудита в транзакции изменения
+// по шаблону event. При смене категорий дополнительно записывается отдельное событие.
+func RecordAudit(tx *gorm.DB, event models.AuditEvent, action string, newsID uint, before, after *models.NewsView) error {
+	event.Action = action
+	event.TargetType = models.AuditTargetNews
+	event.TargetId = strconv.FormatUint(uint64(newsID), 10)
+	if before != nil {
+		event.Before = audit.Snapshot(before)
+	}
+	if after != nil {
+		event.After = audit.Snapshot(after)
+	}
+	if err := audit.Record(tx, event); err != nil {
+		return err
+	}
+
+	oldCategories, newCategories := []uint{}, []uint{}
+	if before != nil {
+		oldCategories = before.Categories
+	}
+	if after != nil {
+		newCategories = after.Categories
+	}
+	if slices.Equal(oldCategories, newCategories) {
+		return nil
+	}
+
+	event.Action = models.AuditNewsCategories
+	event.Before = audit.Snapshot(fiber.Map{"Categories": oldCategories})
+	event.After = audit.Snapshot(fiber.Map{"Categories": newCategories})
+	return audit.Record(tx, event)
+}
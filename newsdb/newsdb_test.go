@@ -0,0 +1,33 @@
+package newsdb
+
+import (
+	"testing"
+
+	"test/auth"
+	"test/models"
+)
+
+func TestIsAuthor(t *testing.T) {
+	userID := uint(7)
+	otherID := uint(8)
+	svc := &auth.Principal{Username: "svc:import", Role: auth.RoleService}
+	user := &auth.Principal{UserID: userID, Username: "alice", Role: models.RoleViewer}
+
+	for _, tc := range []struct {
+		name string
+		news models.News
+		p    *auth.Principal
+		want bool
+	}{
+		{"пользователь из БД по идентификатору", models.News{AuthorId: &userID, AuthorName: "alice"}, user, true},
+		{"чужой идентификатор", models.News{AuthorId: &otherID, AuthorName: "alice"}, user, false},
+		{"пользователь из БД не сопоставляется по имени", models.News{AuthorName: "alice"}, user, false},
+		{"сервисная учетная запись по имени", models.News{AuthorName: "svc:import"}, svc, true},
+		{"имя автора из архива", models.News{AuthorName: "svc:import", AuthorExternal: true}, svc, false},
+		{"новость без автора", models.News{}, &auth.Principal{Role: auth.RoleService}, false},
+	} {
+		if got := IsAuthor(&tc.news, tc.p); got != tc.want {
+			t.Errorf("%s: %v, ожидалось %v", tc.name, got, tc.want)
+		}
+	}
+}
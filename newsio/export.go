@@ -0,0 +1,72 @@
+package newsio
+
+import (
+	"context"
+	"io"
+
+	"test/database"
+	"test/models"
+
+	"gorm.io/gorm"
+)
+
+// Export выгружает все новости с категориями в w в указанном формате и возвращает
+// их число. Новости читаются пачками; после каждой пачки буферы сбрасываются,
+// если w их поддерживает (например, *bufio.Writer потокового ответа).
+func Export(ctx context.Context, w io.Writer, format Format) (int, error) {
+	enc, err := newEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+	flusher, _ := w.(interface{ Flush() error })
+
+	count := 0
+	var batch []models.News
+	err = database.DB.WithContext(ctx).Order("id").FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		records, err := withCategories(tx, batch)
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			if err := enc.Encode(&records[i]); err != nil {
+				return err
+			}
+			count++
+		}
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			return flusher.Flush()
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return count, err
+	}
+	return count, enc.Close()
+}
+
+// withCategories дополняет новости их категориями
+func withCategories(tx *gorm.DB, batch []models.News) ([]Record, error) {
+	ids := make([]uint, len(batch))
+	records := make([]Record, len(batch))
+	index := make(map[uint]*Record, len(batch))
+	for i, news := range batch {
+		ids[i] = news.Id
+		records[i] = Record{Id: news.Id, Title: news.Title, Content: news.Content, Categories: []uint{}, Author: news.AuthorName}
+		if news.ExternalId != nil {
+			records[i].ExternalId = *news.ExternalId
+		}
+		index[news.Id] = &records[i]
+	}
+
+	var categories []models.NewsCategory
+	if err := tx.Session(&gorm.Session{NewDB: true}).Where("news_id IN ?", ids).Order("category_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		index[category.NewsId].Categories = append(index[category.NewsId].Categories, category.CategoryId)
+	}
+	return records, nil
+}
@@ -0,0 +1,261 @@
+package newsio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"test/apperr"
+	"test/validation"
+)
+
+// maxRecordSize наибольший размер строки NDJSON
+const maxRecordSize = 16 << 20
+
+// csvHeader колонки CSV при выгрузке
+var csvHeader = []string{"id", "external_id", "title", "content", "categories", "author"}
+
+// encoder пишет новости в файл одного из форматов
+type encoder interface {
+	Encode(record *Record) error
+	Flush() error // Сбрасывает буферы, чтобы отдать записанное клиенту
+	Close() error // Дописывает окончание файла
+}
+
+func newEncoder(w io.Writer, format Format) (encoder, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw}, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) Encode(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "[\n"
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Flush() error { return nil }
+
+func (e *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(record *Record) error { return e.enc.Encode(record) }
+func (e *ndjsonEncoder) Flush() error                { return nil }
+func (e *ndjsonEncoder) Close() error                { return nil }
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(record *Record) error {
+	categories := make([]string, len(record.Categories))
+	for i, id := range record.Categories {
+		categories[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return e.w.Write([]string{
+		strconv.FormatUint(uint64(record.Id), 10), record.ExternalId, record.Title, record.Content,
+		strings.Join(categories, ";"), record.Author,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error { return e.Flush() }
+
+// decoder читает новости из файла одного из форматов. Next возвращает io.EOF после
+// последней записи. Ошибка в отдельной записи возвращается как *apperr.Error: такую
+// запись можно пропустить и читать дальше. Прочие ошибки прерывают чтение файла.
+type decoder interface {
+	Next() (Record, error)
+}
+
+func newDecoder(r io.Reader, format Format) (decoder, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonDecoder{dec: json.NewDecoder(r)}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+		return &ndjsonDecoder{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVDecoder(r)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// jsonDecoder читает массив JSON по одному элементу
+type jsonDecoder struct {
+	dec     *json.Decoder
+	started bool
+}
+
+func (d *jsonDecoder) Next() (Record, error) {
+	if !d.started {
+		token, err := d.dec.Token()
+		if err != nil || token != json.Delim('[') {
+			return Record{}, errors.New("ожидается массив JSON")
+		}
+		d.dec.DisallowUnknownFields()
+		d.started = true
+	}
+	if !d.dec.More() {
+		if _, err := d.dec.Token(); err != nil {
+			return Record{}, err
+		}
+		return Record{}, io.EOF
+	}
+
+	var record Record
+	err := d.dec.Decode(&record)
+	var syntaxErr *json.SyntaxError
+	if err == nil || errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// После синтаксической ошибки продолжить разбор массива нельзя
+		return record, err
+	}
+	return record, validation.JSONError(err)
+}
+
+// ndjsonDecoder читает объект JSON из каждой непустой строки
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func (d *ndjsonDecoder) Next() (Record, error) {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&record); err != nil {
+			return record, validation.JSONError(err)
+		}
+		if dec.More() {
+			return record, apperr.New(apperr.CodeBadRequest)
+		}
+		return record, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// csvDecoder читает CSV с заголовком. Колонки title и content обязательны,
+// порядок колонок любой.
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("пустой файл CSV")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := &csvDecoder{r: cr, columns: make(map[string]int, len(header))}
+	for i, name := range header {
+		// Excel начинает файл в UTF-8 с метки порядка байтов
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(csvHeader, name) {
+			return nil, fmt.Errorf("неизвестная колонка CSV %q", name)
+		}
+		d.columns[name] = i
+	}
+	for _, name := range []string{"title", "content"} {
+		if _, ok := d.columns[name]; !ok {
+			return nil, fmt.Errorf("в CSV нет колонки %q", name)
+		}
+	}
+	return d, nil
+}
+
+func (d *csvDecoder) Next() (Record, error) {
+	row, err := d.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// Чтение продолжается со следующей строки
+		return Record{}, apperr.Wrap(err, apperr.CodeBadRequest)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{
+		ExternalId: d.value(row, "external_id"),
+		Title:      d.value(row, "title"),
+		Content:    d.value(row, "content"),
+		Author:     d.value(row, "author"),
+	}
+	for i, raw := range strings.FieldsFunc(d.value(row, "categories"), func(r rune) bool { return r == ';' }) {
+		id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+		if err != nil {
+			return record, apperr.Wrap(err, apperr.CodeBadRequest).WithFields([]apperr.FieldError{
+				{Field: fmt.Sprintf("Categories[%d]", i), Code: "type", Param: "uint"},
+			})
+		}
+		record.Categories = append(record.Categories, uint(id))
+	}
+	return record, nil
+}
+
+func (d *csvDecoder) value(row []string, column string) string {
+	if i, ok := d.columns[column]; ok {
+		return row[i]
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package newsio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"test/apperr"
+)
+
+func TestRoundTrip(t *testing.T) {
+	records := []Record{
+		{Id: 1, ExternalId: "cms-1", Title: "Первая", Content: "Текст, с запятой\nи строкой", Categories: []uint{1, 2}, Author: "old-editor"},
+		{Id: 2, Title: "Вторая", Content: `"Кавычки"`, Categories: []uint{}},
+	}
+
+	for _, format := range []Format{FormatJSON, FormatNDJSON, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := newEncoder(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range records {
+				if err := enc.Encode(&records[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := decodeAll(t, &buf, format)
+			if len(got) != len(records) {
+				t.Fatalf("прочитано %d записей из %d", len(got), len(records))
+			}
+			for i, record := range got {
+				// Id при загрузке не используется, в CSV не читается
+				want := records[i]
+				want.Id, record.Id = 0, 0
+				if len(want.Categories) == 0 {
+					want.Categories = nil
+				}
+				if len(record.Categories) == 0 {
+					record.Categories = nil
+				}
+				if !reflect.DeepEqual(record, want) {
+					t.Errorf("запись %d: %+v, ожидалось %+v", i+1, record, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyJSONExport(t *testing.T) {
+	var buf bytes.Buffer
+	enc, _ := newEncoder(&buf, FormatJSON)
+	if err := enc.Close(); err != nil || buf.String() != "[]\n" {
+		t.Fatalf("пустая выгрузка %q, %v", buf.String(), err)
+	}
+}
+
+func TestRecordErrorsDoNotStopReading(t *testing.T) {
+	cases := map[Format]string{
+		FormatJSON:   `[{"Title":"a","Content":"b"},{"Title":1},{"Title":"c","Content":"d","Extra":true},{"Title":"e","Content":"f"}]`,
+		FormatNDJSON: "{\"Title\":\"a\",\"Content\":\"b\"}\n{broken\n\n{\"Title\":\"c\",\"Unknown\":1}\n{\"Title\":\"e\",\"Content\":\"f\"}\n",
+		FormatCSV:    "title,content,categories\na,b,1;2\nx,y,oops\nc,d\ne,f,\n",
+	}
+	for format, input := range cases {
+		t.Run(string(format), func(t *testing.T) {
+			dec, err := newDecoder(strings.NewReader(input), format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			failed := 0
+			for {
+				record, err := dec.Next()
+				if err == io.EOF {
+					break
+				}
+				var appErr *apperr.Error
+				if errors.As(err, &appErr) {
+					failed++
+					continue
+				}
+				if err != nil {
+					t.Fatalf("чтение прервано: %v", err)
+				}
+				titles = append(titles, record.Title)
+			}
+			if failed != 2 || titles[0] != "a" || titles[len(titles)-1] != "e" {
+				t.Fatalf("прочитаны %v, отклонено %d", titles, failed)
+			}
+		})
+	}
+}
+
+func TestFatalErrors(t *testing.T) {
+	if _, err := newDecoder(strings.NewReader("title,unknown\n"), FormatCSV); err == nil {
+		t.Error("неизвестная колонка CSV принята")
+	}
+	if _, err := newDecoder(strings.NewReader("id,title\n"), FormatCSV); err == nil {
+		t.Error("CSV без колонки content принят")
+	}
+
+	dec, _ := newDecoder(strings.NewReader(`[{"Title":"a","Content":"b"} {"Title"`), FormatJSON)
+	if _, err := dec.Next(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dec.Next()
+	var appErr *apperr.Error
+	if err == nil || err == io.EOF || errors.As(err, &appErr) {
+		t.Fatalf("синтаксическая ошибка массива должна прерывать чтение, получено %v", err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, want := range map[string]Format{"a.csv": FormatCSV, "a.NDJSON": FormatNDJSON, "a.jsonl": FormatNDJSON, "a.json": FormatJSON, "-": FormatJSON} {
+		if got := FormatOf(path); got != want {
+			t.Errorf("FormatOf(%q) = %s, ожидалось %s", path, got, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseFormat(xml): %v", err)
+	}
+}
+
+func decodeAll(t *testing.T, r io.Reader, format Format) []Record {
+	t.Helper()
+	dec, err := newDecoder(r, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []Record
+	for {
+		record, err := dec.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("запись %d: %v", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,353 @@
+package newsio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"test/apperr"
+	"test/audit"
+	"test/auth"
+	"test/database"
+	"test/i18n"
+	"test/logger"
+	"test/models"
+	"test/newsdb"
+	"test/validation"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxReportedErrors наибольшее число ошибок в отчете; остальные только считаются в Failed
+const maxReportedErrors = 1000
+
+// errDryRun откатывает транзакцию пачки при пробной загрузке
+var errDryRun = errors.New("пробная загрузка")
+
+// Options параметры загрузки
+type Options struct {
+	// DryRun проверяет и записывает пачки, но откатывает каждую транзакцию:
+	// отчет показывает, что было бы создано, обновлено и отклонено.
+	DryRun bool
+
+	// Importer становится автором новостей без Author и последним редактором обновленных
+	Importer models.NewsAuthor
+
+	// Principal пользователь, от имени которого идет загрузка: обновлять существующие
+	// новости он может только с правом на их редактирование. nil — оператор CLI без ограничений.
+	Principal *auth.Principal
+
+	// Event шаблон события журнала аудита: автор, IP, идентификатор запроса
+	Event models.AuditEvent
+}
+
+// Report итог загрузки
+type Report struct {
+	DryRun  bool          `json:"DryRun"`
+	Total   int           `json:"Total"`   // Прочитано записей
+	Created int           `json:"Created"` // Создано новостей
+	Updated int           `json:"Updated"` // Обновлено новостей по ExternalId
+	Failed  int           `json:"Failed"`  // Отклонено записей
+	Errors  []RecordError `json:"Errors"`  // Первые maxReportedErrors ошибок
+}
+
+// RecordError ошибка в отдельной записи файла
+type RecordError struct {
+	Record     int                 `json:"Record"` // Номер записи в файле, начиная с 1
+	ExternalId string              `json:"ExternalId,omitempty"`
+	Code       string              `json:"Code"`
+	Message    string              `json:"Message"`
+	Fields     []apperr.FieldError `json:"Fields,omitempty"`
+
+	detail     string
+	detailArgs []interface{}
+}
+
+func (r *Report) fail(number int, record *Record, err *apperr.Error) {
+	r.Failed++
+	if len(r.Errors) >= maxReportedErrors {
+		return
+	}
+	r.Errors = append(r.Errors, RecordError{
+		Record:     number,
+		ExternalId: record.ExternalId,
+		Code:       err.Code,
+		Fields:     err.Fields,
+		detail:     err.Detail,
+		detailArgs: err.DetailArgs,
+	})
+}
+
+// Localize заполняет сообщения об ошибках на языке lang
+func (r *Report) Localize(lang string) {
+	for i := range r.Errors {
+		e := &r.Errors[i]
+		e.Message = i18n.Translate(lang, "error."+e.Code)
+		if e.detail != "" {
+			e.Message += ": " + i18n.Translate(lang, e.detail, e.detailArgs...)
+		}
+		for j := range e.Fields {
+			e.Fields[j].Message = apperr.TranslateField(lang, e.Fields[j])
+		}
+	}
+}
+
+// FileError файл нельзя читать дальше: нарушена структура JSON, заголовок CSV
+// или слишком длинная строка. Пачки до записи Record уже загружены.
+type FileError struct {
+	Record int
+	Err    error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("запись %d: %v", e.Record, e.Err)
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// pending запись, прочитанная из файла и ожидающая записи в БД
+type pending struct {
+	number int
+	record Record
+}
+
+// Import загружает новости из r пачками по batchSize, каждая пачка — в своей транзакции.
+// Записи с ошибками разбора или проверки пропускаются и попадают в отчет, остальные
+// записи пачки сохраняются. Новость с уже известным ExternalId обновляется, иначе
+// создается новая. Ошибка возвращается, только если файл дальше читать нельзя или
+// недоступна БД; уже зафиксированные пачки при этом остаются в БД и в отчете,
+// который возвращается всегда.
+func Import(ctx context.Context, r io.Reader, format Format, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Errors: []RecordError{}}
+	dec, err := newDecoder(r, format)
+	if errors.Is(err, ErrUnknownFormat) {
+		return report, err
+	}
+	if err != nil {
+		return report, &FileError{Record: 1, Err: err}
+	}
+
+	batch := make([]pending, 0, batchSize)
+	for {
+		record, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		report.Total++
+
+		var appErr *apperr.Error
+		if err == nil {
+			err = validation.Struct(&record)
+		}
+		if errors.As(err, &appErr) {
+			report.fail(report.Total, &record, appErr)
+			continue
+		}
+		if err != nil {
+			report.Total--
+			return report, &FileError{Record: report.Total + 1, Err: err}
+		}
+
+		batch = append(batch, pending{number: report.Total, record: record})
+		if len(batch) == batchSize {
+			if err := importBatch(ctx, batch, opts, report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := importBatch(ctx, batch, opts, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// importBatch записывает пачку в одной транзакции. Каждая запись сохраняется в точке
+// сохранения вместе со своим событием журнала аудита: ошибка записи откатывает только ее.
+func importBatch(ctx context.Context, batch []pending, opts Options, report *Report) error {
+	type failure struct {
+		pending
+		err *apperr.Error
+	}
+
+	var created, updated int
+	var failed []failure
+	err := audit.Transaction(database.DB.WithContext(ctx), func(tx *gorm.DB) error {
+		existing, err := findExisting(tx, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range batch {
+			var news *models.News
+			var isUpdate bool
+			err := audit.Savepoint(tx, func(sp *gorm.DB) error {
+				var err error
+				news, isUpdate, err = saveRecord(sp, &p.record, existing, opts)
+				return err
+			})
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			var appErr *apperr.Error
+			if errors.As(err, &appErr) {
+				failed = append(failed, failure{p, appErr})
+				continue
+			}
+			if err != nil {
+				logger.Logger.WithError(err).WithField("record", p.number).Error("Ошибка загрузки новости")
+				failed = append(failed, failure{p, apperr.New(apperr.CodeInternal)})
+				continue
+			}
+
+			if p.record.ExternalId != "" {
+				existing[p.record.ExternalId] = news
+			}
+			if isUpdate {
+				updated++
+			} else {
+				created++
+			}
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+
+	report.Created += created
+	report.Updated += updated
+	for _, f := range failed {
+		report.fail(f.number, &f.record, f.err)
+	}
+	return nil
+}
+
+// findExisting находит и блокирует новости пачки с известными ExternalId
+func findExisting(tx *gorm.DB, batch []pending) (map[string]*models.News, error) {
+	existing := make(map[string]*models.News)
+	ids := make([]string, 0, len(batch))
+	for _, p := range batch {
+		if p.record.ExternalId != "" {
+			ids = append(ids, p.record.ExternalId)
+		}
+	}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	var rows []models.News
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "external_id", "author_id", "author_name", "author_external").
+		Where("external_id IN ?", ids).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		existing[*rows[i].ExternalId] = &rows[i]
+	}
+	return existing, nil
+}
+
+// saveRecord создает новость или обновляет найденную по ExternalId вместе с категориями
+// и записывает изменение в журнал аудита. Автор существующей новости не меняется.
+func saveRecord(tx *gorm.DB, record *Record, existing map[string]*models.News, opts Options) (*models.News, bool, error) {
+	if news, ok := existing[record.ExternalId]; ok && record.ExternalId != "" {
+		if opts.Principal != nil {
+			allowed, err := newsdb.CanEdit(tx, news, opts.Principal)
+			if err != nil {
+				return nil, true, err
+			}
+			if !allowed {
+				return nil, true, apperr.New(apperr.CodeNewsForbidden).WithDetail("detail.news_edit_forbidden")
+			}
+		}
+
+		before, err := newsdb.Snapshot(tx, news.Id)
+		if err != nil {
+			return nil, true, err
+		}
+		changes := map[string]interface{}{
+			"title":            record.Title,
+			"content":          record.Content,
+			"last_editor_id":   opts.Importer.Id,
+			"last_editor_name": opts.Importer.Username,
+		}
+		if err := tx.Model(&models.News{}).Where("id = ?", news.Id).Updates(changes).Error; err != nil {
+			return nil, true, err
+		}
+		if err := tx.Where("news_id = ?", news.Id).Delete(&models.NewsCategory{}).Error; err != nil {
+			return nil, true, err
+		}
+		if err := newsdb.SetCategories(tx, news.Id, record.Categories); err != nil {
+			return nil, true, err
+		}
+		return news, true, recordAudit(tx, opts.Event, models.AuditNewsEdit, news.Id, before)
+	}
+
+	news := models.News{Title: record.Title, Content: record.Content}
+	if record.ExternalId != "" {
+		externalID := record.ExternalId
+		news.ExternalId = &externalID
+	}
+	if err := setAuthor(tx, &news, record.Author, opts); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Create(&news).Error; err != nil {
+		return nil, false, err
+	}
+	if err := newsdb.SetCategories(tx, news.Id, record.Categories); err != nil {
+		return nil, false, err
+	}
+	return &news, false, recordAudit(tx, opts.Event, models.AuditNewsCreate, news.Id, nil)
+}
+
+// setAuthor назначает автора новой новости. Автор из архива связывается с пользователем
+// с тем же именем, только если загружает администратор или оператор CLI: иначе
+// редактор мог бы приписать новость любому пользователю. В остальных случаях
+// сохраняется только имя без прав на новость. Без Author автором становится загрузивший.
+func setAuthor(tx *gorm.DB, news *models.News, author string, opts Options) error {
+	if author == "" {
+		news.AuthorId = opts.Importer.Id
+		news.AuthorName = opts.Importer.Username
+		return nil
+	}
+	if opts.Principal != nil && opts.Principal.Role != models.RoleAdmin {
+		news.AuthorName = author
+		news.AuthorExternal = true
+		return nil
+	}
+
+	var user models.User
+	err := tx.Select("id", "username").Where("LOWER(username) = LOWER(?)", author).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		news.AuthorName = author
+		news.AuthorExternal = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	news.AuthorId = &user.Id
+	news.AuthorName = user.Username
+	return nil
+}
+
+// recordAudit записывает в журнал создание или изменение новости с состоянием до и после
+func recordAudit(tx *gorm.DB, event models.AuditEvent, action string, newsID uint, before *models.NewsView) error {
+	after, err := newsdb.Snapshot(tx, newsID)
+	if err != nil {
+		return err
+	}
+	return newsdb.RecordAudit(tx, event, action, newsID, before, after)
+}
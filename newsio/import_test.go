@@ -0,0 +1,33 @@
+package newsio
+
+import (
+	"testing"
+
+	"test/auth"
+	"test/models"
+)
+
+func TestSetAuthorNotAdmin(t *testing.T) {
+	// Автор из архива у редактора не связывается с пользователем: БД не запрашивается
+	editorID := uint(3)
+	opts := Options{
+		Importer:  models.NewsAuthor{Id: &editorID, Username: "editor"},
+		Principal: &auth.Principal{UserID: editorID, Username: "editor", Role: models.RoleEditor},
+	}
+
+	var news models.News
+	if err := setAuthor(nil, &news, "admin", opts); err != nil {
+		t.Fatal(err)
+	}
+	if news.AuthorId != nil || !news.AuthorExternal || news.AuthorName != "admin" {
+		t.Fatalf("автор %+v, ожидалось внешнее имя admin", news)
+	}
+
+	news = models.News{}
+	if err := setAuthor(nil, &news, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	if news.AuthorId == nil || *news.AuthorId != editorID || news.AuthorExternal {
+		t.Fatalf("без Author автором должен стать загрузивший: %+v", news)
+	}
+}
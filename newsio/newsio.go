@@ -0,0 +1,68 @@
+// Package newsio выгрузка и загрузка архива новостей с категориями в форматах
+// JSON, NDJSON и CSV. Файлы читаются и пишутся потоком, пачками по batchSize новостей,
+// поэтому размер архива ограничен только диском, а не памятью.
+package newsio
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// batchSize число новостей, читаемых из БД или загружаемых в одной транзакции
+const batchSize = 500
+
+// Format формат файла выгрузки и загрузки
+type Format string
+
+const (
+	FormatJSON   Format = "json"   // Массив JSON
+	FormatNDJSON Format = "ndjson" // Объект JSON на строку
+	FormatCSV    Format = "csv"    // CSV с заголовком, категории через ';'
+)
+
+// ErrUnknownFormat неизвестный формат файла
+var ErrUnknownFormat = errors.New("неизвестный формат")
+
+// ParseFormat разбирает название формата
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatJSON, FormatNDJSON, FormatCSV:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, name)
+}
+
+// FormatOf определяет формат по расширению файла; для неизвестных расширений — JSON
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".csv":
+		return FormatCSV
+	}
+	return FormatJSON
+}
+
+// ContentType тип содержимого файла в формате
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/json"
+}
+
+// Record новость в файле выгрузки и загрузки. Id при загрузке не используется:
+// существующие новости находятся по ExternalId.
+type Record struct {
+	Id         uint   `json:"Id,omitempty"`
+	ExternalId string `json:"ExternalId,omitempty" validate:"max=255"`
+	Title      string `json:"Title" validate:"required,max=255"`
+	Content    string `json:"Content" validate:"required"`
+	Categories []uint `json:"Categories" validate:"max=50,unique,dive,gt=0"`
+	Author     string `json:"Author,omitempty" validate:"max=255"`
+}
@@ -0,0 +1,14 @@
+package openapi
+
+import _ "embed"
+
+// Spec спецификация API в формате OpenAPI 3.1. Новые маршруты нужно описывать здесь:
+// тест в пакете routes сверяет спецификацию с зарегистрированными маршрутами.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// DocsPage страница документации (Redoc), загружающая Spec с /openapi.json
+//
+//go:embed docs.html
+var DocsPage []byte
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"test/logger"
+	"test/models"
+
+	"gorm.io/gorm"
+)
+
+// PostgresStore хранит счетчики фиксированных окон в таблице rate_limit_counters
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore создает хранилище и запускает периодическую очистку истекших окон
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	s := &PostgresStore{db: db}
+	go s.cleanup(time.Minute)
+	return s
+}
+
+func (s *PostgresStore) Hit(ctx context.Context, key string, window time.Duration, cost int) (int, time.Time, error) {
+	now := time.Now().UTC()
+	windowStart := now.Truncate(window)
+	reset := windowStart.Add(window)
+
+	var count int
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO rate_limit_counters (key, window_start, count, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limit_counters.count + EXCLUDED.count
+		RETURNING count`,
+		key, windowStart, cost, reset,
+	).Scan(&count).Error
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return count, reset, nil
+}
+
+func (s *PostgresStore) cleanup(interval time.Duration) {
+	for range time.NewTicker(interval).C {
+		err := s.db.Where("expires_at < ?", time.Now().UTC()).Delete(&models.RateLimitCounter{}).Error
+		if err != nil {
+			logger.Logger.WithError(err).Warn("Ошибка очистки счетчиков ограничения частоты")
+		}
+	}
+}
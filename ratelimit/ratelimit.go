@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule правило ограничения: не более Limit запросов за Window
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Enabled сообщает, задано ли ограничение
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// ParseRule разбирает правило вида "10/1m". Значения "", "0" и "off" отключают ограничение.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || s == "off" {
+		return Rule{}, nil
+	}
+
+	limit, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("неверный формат правила %q, ожидается <количество>/<период>", s)
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 0 {
+		return Rule{}, fmt.Errorf("неверное количество запросов в правиле %q", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Rule{}, fmt.Errorf("неверный период в правиле %q", s)
+	}
+
+	return Rule{Limit: n, Window: d}, nil
+}
+
+// Store хранилище счетчиков, общее для всех процессов приложения
+type Store interface {
+	// Hit увеличивает счетчик ключа в текущем окне на cost и возвращает его значение и время сброса
+	Hit(ctx context.Context, key string, window time.Duration, cost int) (count int, reset time.Time, err error)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"test/handlers"
+	"test/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterAccountRoutes(app *fiber.App) {
+	api := app.Group("/api")
+
+	forgotLimit := middleware.NewRateLimiter("password_forgot", "RATE_LIMIT_PASSWORD_FORGOT_IP", "5/1m", middleware.KeyByIP)
+
+	api.Post("/password/forgot", forgotLimit, handlers.ForgotPassword) // Запрос письма для сброса пароля
+	api.Post("/password/reset", handlers.ResetPassword)                // Сброс пароля по токену
+	api.Post("/email/verify", handlers.VerifyEmail)                    // Подтверждение адреса по токену
+
+	// Повторная отправка письма для подтверждения адреса
+	api.Post("/email/verify/request", middleware.AuthMiddleware, handlers.RequestEmailVerification)
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"test/handlers"
+	"test/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterAdminRoutes(app *fiber.App) {
+	// Административные маршруты (требуют JWT-токен с ролью admin)
+	admin := app.Group("/api/admin", middleware.AuthMiddleware, middleware.RequireRole("admin"), middleware.RequireMFA)
+
+	admin.Get("/log-level", handlers.GetLogLevel) // Текущий уровень логгирования
+	admin.Put("/log-level", handlers.SetLogLevel) // Смена уровня логгирования
+
+	admin.Post("/users", handlers.CreateUser)                     // Создание пользователя
+	admin.Post("/users/:username/unlock", handlers.UnlockAccount) // Снятие блокировки входа
+	admin.Put("/users/:username/role", handlers.SetUserRole)      // Смена роли пользователя
+
+	admin.Get("/users/:username/sessions", handlers.ListUserSessions)         // Сеансы пользователя
+	admin.Delete("/users/:username/sessions", handlers.RevokeUserSessions)    // Отзыв всех сеансов пользователя
+	admin.Delete("/users/:username/sessions/:id", handlers.RevokeUserSession) // Отзыв сеанса пользователя
+
+	admin.Post("/api-keys", handlers.CreateAPIKey)            // Выпуск API-ключа
+	admin.Get("/api-keys", handlers.ListAPIKeys)              // Список API-ключей
+	admin.Post("/api-keys/:id/rotate", handlers.RotateAPIKey) // Перевыпуск API-ключа
+	admin.Delete("/api-keys/:id", handlers.RevokeAPIKey)      // Отзыв API-ключа
+
+	admin.Get("/audit", handlers.ListAuditEvents)          // Журнал аудита с фильтрами
+	admin.Get("/audit/export", handlers.ExportAuditEvents) // Выгрузка журнала аудита (ndjson, csv)
+	admin.Get("/audit/verify", handlers.VerifyAuditLog)    // Проверка цепочки хешей журнала
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"test/handlers"
+	"test/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -9,5 +10,27 @@ import (
 func RegisterAuthRoutes(app *fiber.App) {
 	api := app.Group("/api")
 
-	api.Post("/login", handlers.LoginHandler)
+	// Ограничение попыток входа по IP и по имени пользователя
+	loginByIP := middleware.NewRateLimiter("login_ip", "RATE_LIMIT_LOGIN_IP", "20/1m", middleware.KeyByIP)
+	loginByUser := middleware.NewRateLimiter("login_user", "RATE_LIMIT_LOGIN_USER", "5/1m", middleware.KeyByLoginUsername)
+
+	api.Post("/login", loginByIP, loginByUser, handlers.LoginHandler)
+	api.Post("/login/mfa", loginByIP, handlers.LoginMFA)         // Второй шаг входа с кодом TOTP
+	api.Post("/token/refresh", loginByIP, handlers.RefreshToken) // Обновление токена доступа по refresh-токену
+
+	// Вход через OpenID Connect
+	api.Get("/oidc/login", loginByIP, handlers.OIDCLogin)
+	api.Get("/oidc/callback", loginByIP, handlers.OIDCCallback)
+
+	// Подключение и отключение TOTP (доступно без второго фактора, чтобы его можно было настроить)
+	mfa := api.Group("/mfa/totp", middleware.AuthMiddleware)
+	mfa.Post("/enroll", handlers.EnrollTOTP)
+	mfa.Post("/confirm", handlers.ConfirmTOTP)
+	mfa.Post("/disable", handlers.DisableTOTP)
+
+	// Сеансы текущего пользователя
+	sessions := api.Group("/sessions", middleware.AuthMiddleware)
+	sessions.Get("/", handlers.ListSessions)
+	sessions.Post("/revoke-others", handlers.RevokeOtherSessions)
+	sessions.Delete("/:id", handlers.RevokeSession)
 }
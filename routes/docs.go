@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"test/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterDocsRoutes(app *fiber.App) {
+	app.Get("/openapi.json", handlers.OpenAPISpec) // Спецификация OpenAPI
+	app.Get("/docs", handlers.APIDocs)             // Документация API
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"test/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterHealthRoutes(app *fiber.App) {
+	app.Get("/healthz", handlers.Healthz) // Процесс жив
+	app.Get("/readyz", handlers.Readyz)   // Готовность к обработке запросов
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"test/metrics"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func RegisterMetricsRoutes(app *fiber.App) {
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Gatherer(), promhttp.HandlerOpts{})))
+}
@@ -1,8 +1,10 @@
 package routes
 
 import (
+	"test/auth"
 	"test/handlers"
 	"test/middleware"
+	"test/models"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,11 +12,30 @@ import (
 func RegisterNewsRoutes(app *fiber.App) {
 	api := app.Group("/api")
 
-	// Защищенные маршруты (требуют JWT-токен)
-	protected := api.Group("/", middleware.AuthMiddleware)
+	// Защищенные маршруты (требуют JWT-токен и второй фактор по политике ролей)
+	protected := api.Group("/", middleware.AuthMiddleware, middleware.RequireMFA)
 
-	protected.Post("/create", handlers.CreateNews)       // Создание новости
-	protected.Post("/edit/:Id", handlers.EditNews)       // Редактирование новости
-	protected.Delete("/delete/:Id", handlers.DeleteNews) // Удаление новости
-	protected.Get("/list", handlers.GetNewsList)
+	// Ограничения частоты: запись — по пользователю, чтение — по IP
+	writeLimit := middleware.NewRateLimiter("write", "RATE_LIMIT_WRITE_USER", "60/1m", middleware.KeyByUser)
+	// Пакет расходует тот же лимит записи по числу операций
+	batchLimit := middleware.NewWeightedRateLimiter("write", "RATE_LIMIT_WRITE_USER", "60/1m", middleware.KeyByUser, middleware.CostByBatchOperations)
+	readLimit := middleware.NewRateLimiter("read", "RATE_LIMIT_READ_IP", "300/1m", middleware.KeyByIP)
+
+	// Области доступа для API-ключей
+	canWrite := middleware.RequireScope(models.ScopeNewsWrite)
+	canRead := middleware.RequireScope(models.ScopeNewsRead)
+
+	// Запись, выгрузка и загрузка архива — для редакторов и сервисных учетных записей.
+	// Области доступа ограничивают только API-ключи, поэтому роль проверяется отдельно.
+	canEdit := middleware.RequireRole(models.RoleAdmin, models.RoleEditor, auth.RoleService)
+	canBulk := canEdit
+
+	protected.Post("/create", canWrite, canEdit, writeLimit, handlers.CreateNews)       // Создание новости
+	protected.Post("/edit/:Id", canWrite, canEdit, writeLimit, handlers.EditNews)       // Редактирование новости
+	protected.Delete("/delete/:Id", canWrite, canEdit, writeLimit, handlers.DeleteNews) // Удаление новости
+	protected.Post("/news\\:batch", canWrite, canEdit, batchLimit, handlers.BatchNews)  // Пакетная запись новостей
+	protected.Get("/list", canRead, readLimit, handlers.GetNewsList)
+	protected.Get("/news/export", canRead, canBulk, readLimit, handlers.ExportNews)    // Выгрузка всех новостей
+	protected.Post("/news/import", canWrite, canBulk, writeLimit, handlers.ImportNews) // Загрузка новостей потоком
+	protected.Get("/news/:Id", canRead, readLimit, handlers.GetNews)                   // Новость с авторами
 }
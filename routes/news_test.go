@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test/apperr"
+	"test/auth"
+	"test/logger"
+	"test/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func TestNewsWriteRequiresEditorRole(t *testing.T) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	viper.Set("JWT_SECRET", "test-secret")
+	t.Cleanup(func() { viper.Set("JWT_SECRET", nil) })
+
+	token, err := auth.IssueAccessToken(auth.Principal{Username: "viewer", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.Handler})
+	RegisterNewsRoutes(app)
+
+	for _, tc := range []struct{ method, path string }{
+		{fiber.MethodPost, "/api/create"},
+		{fiber.MethodPost, "/api/edit/1"},
+		{fiber.MethodDelete, "/api/delete/1"},
+		{fiber.MethodPost, "/api/news:batch"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"Title":"t","Content":"c"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("%s %s: статус %d, ожидался 403", tc.method, tc.path, resp.StatusCode)
+		}
+	}
+}
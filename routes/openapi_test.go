@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"test/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// paramPattern параметр маршрута Fiber (:id) для перевода в вид OpenAPI ({id});
+// экранированное двоеточие (\:) параметром не является
+var paramPattern = regexp.MustCompile(`(^|[^\\]):([A-Za-z0-9_]+)`)
+
+// specPath приводит путь маршрута Fiber к пути OpenAPI
+func specPath(path string) string {
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	path = paramPattern.ReplaceAllString(path, "$1{$2}")
+	return strings.ReplaceAll(path, `\:`, ":")
+}
+
+func loadSpec(t *testing.T) map[string]map[string]json.RawMessage {
+	t.Helper()
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("спецификация не разбирается: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.1") {
+		t.Fatalf("ожидается OpenAPI 3.1, в спецификации %q", spec.OpenAPI)
+	}
+	return spec.Paths
+}
+
+// TestSpecCoversRoutes падает, если зарегистрированный маршрут не описан в спецификации
+func TestSpecCoversRoutes(t *testing.T) {
+	paths := loadSpec(t)
+
+	app := fiber.New()
+	Register(app)
+
+	var missing []string
+	for _, route := range app.GetRoutes(true) {
+		// HEAD Fiber добавляет к каждому GET автоматически
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+		path := specPath(route.Path)
+		if _, ok := paths[path][strings.ToLower(route.Method)]; !ok {
+			missing = append(missing, route.Method+" "+path)
+		}
+	}
+
+	sort.Strings(missing)
+	for _, route := range missing {
+		t.Errorf("маршрут %s не описан в openapi/openapi.json", route)
+	}
+}
+
+// TestSpecHasNoStaleRoutes падает, если в спецификации описан несуществующий маршрут
+func TestSpecHasNoStaleRoutes(t *testing.T) {
+	paths := loadSpec(t)
+
+	app := fiber.New()
+	Register(app)
+
+	registered := make(map[string]bool)
+	for _, route := range app.GetRoutes(true) {
+		registered[strings.ToLower(route.Method)+" "+specPath(route.Path)] = true
+	}
+
+	for path, operations := range paths {
+		for method := range operations {
+			if method == "parameters" || method == "summary" || method == "description" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf("в спецификации описан несуществующий маршрут %s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
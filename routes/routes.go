@@ -0,0 +1,14 @@
+package routes
+
+import "github.com/gofiber/fiber/v2"
+
+// Register регистрирует все маршруты приложения
+func Register(app *fiber.App) {
+	RegisterHealthRoutes(app)  // Проверки liveness/readiness
+	RegisterMetricsRoutes(app) // Метрики Prometheus
+	RegisterDocsRoutes(app)    // Спецификация OpenAPI и документация
+	RegisterAuthRoutes(app)    // Маршруты аутентификации
+	RegisterAccountRoutes(app) // Сброс пароля и подтверждение адреса
+	RegisterAdminRoutes(app)   // Административные маршруты
+	RegisterNewsRoutes(app)    // Защищенные маршруты (группа /api/ с AuthMiddleware, регистрируется последней)
+}
@@ -0,0 +1,121 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret ключ SHA-1 из приложения B RFC 6238 ("12345678901234567890") в base32
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// Контрольные значения RFC 6238 (SHA-1). В RFC коды восьмизначные, шестизначный
+// код — последние шесть цифр того же значения.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestGenerateRFC6238(t *testing.T) {
+	key, err := encoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range rfcVectors {
+		step := v.unix / int64(Period.Seconds())
+		want := v.code[len(v.code)-Digits:]
+		if got := generate(key, step); got != want {
+			t.Errorf("время %d: код %s, ожидался %s", v.unix, got, want)
+		}
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		now := time.Unix(v.unix, 0)
+		step, ok := Validate(rfcSecret, v.code[len(v.code)-Digits:], now, 0)
+		if !ok {
+			t.Errorf("время %d: код не принят", v.unix)
+			continue
+		}
+		if want := v.unix / int64(Period.Seconds()); step != want {
+			t.Errorf("время %d: шаг %d, ожидался %d", v.unix, step, want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	key, _ := encoding.DecodeString(rfcSecret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / int64(Period.Seconds())
+
+	for offset := int64(-Skew - 1); offset <= Skew+1; offset++ {
+		code := generate(key, current+offset)
+		step, ok := Validate(rfcSecret, code, now, 0)
+		inWindow := offset >= -Skew && offset <= Skew
+		if ok != inWindow {
+			t.Errorf("смещение %d шагов: принят=%v, ожидалось %v", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("смещение %d шагов: шаг %d, ожидался %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestValidateRejectsUsedSteps(t *testing.T) {
+	key, _ := encoding.DecodeString(rfcSecret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / int64(Period.Seconds())
+	code := generate(key, current)
+
+	step, ok := Validate(rfcSecret, code, now, 0)
+	if !ok || step != current {
+		t.Fatalf("первая проверка: шаг %d, принят=%v", step, ok)
+	}
+	// Повторное использование того же кода
+	if _, ok := Validate(rfcSecret, code, now, step); ok {
+		t.Error("код текущего шага принят повторно")
+	}
+	// Код предыдущего шага в пределах Skew, но не позже lastStep
+	if _, ok := Validate(rfcSecret, generate(key, current-1), now, current); ok {
+		t.Error("принят код шага раньше lastStep")
+	}
+	// Код следующего шага после lastStep принимается
+	if next, ok := Validate(rfcSecret, generate(key, current+1), now, current); !ok || next != current+1 {
+		t.Errorf("код следующего шага: шаг %d, принят=%v", next, ok)
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, tc := range []struct{ secret, code string }{
+		{rfcSecret, "28708"},
+		{rfcSecret, "2870820"},
+		{rfcSecret, ""},
+		{"не base32", "287082"},
+	} {
+		if _, ok := Validate(tc.secret, tc.code, now, 0); ok {
+			t.Errorf("принят код %q с секретом %q", tc.code, tc.secret)
+		}
+	}
+	// Пробелы и нижний регистр секрета допускаются
+	if _, ok := Validate(" "+strings.ToLower(rfcSecret)+" ", " 287082 ", now, 0); !ok {
+		t.Error("не принят код с пробелами и секретом в нижнем регистре")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("News", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	for _, want := range []string{"otpauth://totp/News:alice@example.com?", "secret=JBSWY3DPEHPK3PXP", "issuer=News", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("в %q нет %q", uri, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "test"
+
+// Tracer возвращает трассировщик приложения
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Init настраивает экспорт трассировок. Экспортер выбирается переменной TRACING_EXPORTER:
+// otlp (OTLP/HTTP на TRACING_OTLP_ENDPOINT), stdout, file (в TRACING_FILE) или none.
+// Возвращает функцию для сброса буферов при остановке.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	// W3C traceparent/tracestate и baggage
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	serviceName := viper.GetString("TRACING_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "news-app"
+	}
+
+	ratio := 1.0
+	if viper.IsSet("TRACING_SAMPLE_RATIO") {
+		ratio = viper.GetFloat64("TRACING_SAMPLE_RATIO")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch exporter := viper.GetString("TRACING_EXPORTER"); exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if endpoint := viper.GetString("TRACING_OTLP_ENDPOINT"); endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		if viper.GetBool("TRACING_OTLP_INSECURE") {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		path := viper.GetString("TRACING_FILE")
+		if path == "" {
+			path = "traces.json"
+		}
+		f, err := os.OpenFile(processFile(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка открытия файла трассировок: %v", err)
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировок: %s", exporter)
+	}
+}
+
+// processFile файл трассировок текущего процесса. Дочерние процессы prefork пишут
+// каждый в свой файл (traces.<pid>.json), иначе записи из разных процессов перемешиваются.
+func processFile(path string) string {
+	if !fiber.IsChild() {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strconv.Itoa(os.Getpid()) + ext
+}
@@ -0,0 +1,189 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/apperr"
+	"test/auth"
+	"test/logger"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// errTrailingData в теле запроса после JSON-объекта есть лишние данные
+var errTrailingData = errors.New("данные после JSON-объекта")
+
+// validate проверяет DTO запросов по тегам validate. Правила описываются
+// у полей структур, например `validate:"required,max=255"`.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// В ошибках поля называются так же, как в JSON
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	rules := map[string]validator.Func{
+		// Область доступа API-ключа
+		"scope": func(fl validator.FieldLevel) bool {
+			return auth.ValidScope(fl.Field().String())
+		},
+		// IP-адрес или подсеть в нотации CIDR
+		"ip_rule": func(fl validator.FieldLevel) bool {
+			return auth.ValidIPRule(fl.Field().String())
+		},
+		// Уровень логгирования logrus
+		"log_level": func(fl validator.FieldLevel) bool {
+			_, err := logrus.ParseLevel(fl.Field().String())
+			return err == nil
+		},
+		// Момент времени в будущем
+		"future": func(fl validator.FieldLevel) bool {
+			t, ok := fl.Field().Interface().(time.Time)
+			return ok && t.After(time.Now())
+		},
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			panic(err)
+		}
+	}
+	return v
+}
+
+// Struct проверяет структуру по тегам validate. Нарушения возвращаются одной ошибкой
+// validation_failed со списком полей.
+func Struct(v interface{}) error {
+	err := validate.Struct(v)
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := make([]apperr.FieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		fields = append(fields, fieldError(fe))
+	}
+	return apperr.Wrap(err, apperr.CodeValidationFailed).WithFields(fields)
+}
+
+// Fields возвращает ошибки полей из ошибки Struct или Body
+func Fields(err error) []apperr.FieldError {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+// Body разбирает тело запроса в v и проверяет его. JSON разбирается строго:
+// неизвестные поля и данные после объекта отклоняются.
+func Body(c *fiber.Ctx, v interface{}) error {
+	log := logger.Ctx(c)
+
+	if err := decode(c, v); err != nil {
+		log.WithError(err).Warn("Ошибка парсинга тела запроса")
+		return err
+	}
+	if err := Struct(v); err != nil {
+		log.WithError(err).Warn("Тело запроса не прошло проверку")
+		return err
+	}
+	return nil
+}
+
+// fieldError переводит ошибку валидатора в ошибку поля со стабильным кодом
+func fieldError(fe validator.FieldError) apperr.FieldError {
+	// Namespace начинается с имени структуры: Request.Categories[1] -> Categories[1]
+	_, field, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		field = fe.Field()
+	}
+
+	code, param := fe.Tag(), fe.Param()
+	switch code {
+	case "min", "max":
+		// Для строк ограничивается длина, для списков — число элементов
+		switch fe.Kind() {
+		case reflect.String:
+			code += "_length"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			code += "_items"
+		}
+	case "required_without", "required_with", "required_if", "required_unless":
+		code, param = "required", ""
+	}
+	return apperr.FieldError{Field: field, Code: code, Param: param}
+}
+
+// decode разбирает тело запроса. JSON декодируется с DisallowUnknownFields,
+// остальные типы содержимого — стандартным BodyParser.
+func decode(c *fiber.Ctx, v interface{}) error {
+	contentType := utils.ToLower(string(c.Request().Header.ContentType()))
+	if !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		if err := c.BodyParser(v); err != nil {
+			return apperr.Wrap(err, apperr.CodeBadRequest)
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil && decoder.More() {
+		err = errTrailingData
+	}
+	if err == nil {
+		return nil
+	}
+
+	return JSONError(err)
+}
+
+// JSONError приводит ошибку разбора JSON к bad_request. Ошибки, относящиеся
+// к конкретному полю, отдаются в списке полей.
+func JSONError(err error) *apperr.Error {
+	appErr := apperr.Wrap(err, apperr.CodeBadRequest)
+	var typeErr *json.UnmarshalTypeError
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		appErr.WithFields([]apperr.FieldError{{Field: strings.Trim(field, `"`), Code: "unknown_field"}})
+	} else if errors.As(err, &typeErr) && typeErr.Field != "" {
+		appErr.WithFields([]apperr.FieldError{{Field: jsonPath(typeErr.Field), Code: "type", Param: typeErr.Type.String()}})
+	}
+	return appErr
+}
+
+// jsonPath приводит путь encoding/json к виду путей валидатора: Categories.0 -> Categories[0]
+func jsonPath(path string) string {
+	parts := strings.Split(path, ".")
+	var b strings.Builder
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}